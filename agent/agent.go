@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/llm"
+)
+
+// ErrMaxSteps 表示在达到最大步数前模型仍未给出最终答案
+var ErrMaxSteps = errors.New("agent: max steps exceeded")
+
+// ConfirmFunc 在执行破坏性工具前被调用，返回 false 表示拒绝执行
+type ConfirmFunc func(ctx context.Context, call llm.ToolCall) (bool, error)
+
+// Agent 循环调用模型并执行模型请求的工具，直到得到最终答案
+type Agent struct {
+	llm      llm.LLM
+	registry *Registry
+	maxSteps int
+	confirm  ConfirmFunc
+	options  llm.ChatCompletionOptions
+}
+
+// Result 是一次 Agent 运行的结果
+type Result struct {
+	Answer   string        // 模型的最终答案
+	Messages []llm.Message // 完整的对话记录，包括工具调用和结果
+	Steps    int           // 调用模型的次数
+}
+
+type Option func(a *Agent)
+
+// WithMaxSteps 设置最多调用模型的次数，默认为 10
+func WithMaxSteps(steps int) Option {
+	return func(a *Agent) {
+		a.maxSteps = steps
+	}
+}
+
+// WithConfirm 设置破坏性工具的确认函数，未设置时破坏性工具一律拒绝执行
+func WithConfirm(confirm ConfirmFunc) Option {
+	return func(a *Agent) {
+		a.confirm = confirm
+	}
+}
+
+// WithChatOptions 设置每次调用模型时使用的生成参数，其中的 Messages 和 Tools 会被忽略
+func WithChatOptions(opts llm.ChatCompletionOptions) Option {
+	return func(a *Agent) {
+		a.options = opts
+	}
+}
+
+// New 创建一个新的 Agent 实例
+func New(l llm.LLM, registry *Registry, opts ...Option) (*Agent, error) {
+	if l == nil {
+		return nil, errors.New("nil llm")
+	}
+	if registry == nil {
+		registry = &Registry{tools: make(map[string]Tool)}
+	}
+	a := &Agent{
+		llm:      l,
+		registry: registry,
+		maxSteps: 10,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.maxSteps <= 0 {
+		return nil, errors.New("max steps must be positive")
+	}
+	return a, nil
+}
+
+// Run 从给定的消息开始运行 Agent，直到模型给出最终答案或达到最大步数
+func (a *Agent) Run(ctx context.Context, messages []llm.Message) (*Result, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("empty messages")
+	}
+	result := &Result{
+		Messages: append([]llm.Message(nil), messages...),
+	}
+
+	for result.Steps < a.maxSteps {
+		opts := a.options
+		opts.Messages = result.Messages
+		opts.Tools = a.registry.Definitions()
+
+		resp, err := a.llm.ChatCompletion(ctx, opts)
+		if err != nil {
+			return result, err
+		}
+		result.Steps++
+		result.Messages = append(result.Messages, resp.Message)
+
+		if len(resp.Message.ToolCalls) == 0 {
+			result.Answer = resp.Message.Content
+			return result, nil
+		}
+
+		for _, call := range resp.Message.ToolCalls {
+			content, err := a.execute(ctx, call)
+			if err != nil {
+				return result, err
+			}
+			result.Messages = append(result.Messages, llm.NewToolMessage(call, content))
+		}
+	}
+	return result, ErrMaxSteps
+}
+
+// execute 执行一次工具调用，工具自身的错误作为结果返回给模型，只有 ctx 被取消时才返回错误
+func (a *Agent) execute(ctx context.Context, call llm.ToolCall) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	tool, ok := a.registry.Get(call.Name)
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", call.Name), nil
+	}
+	if isDestructive(tool) {
+		if a.confirm == nil {
+			return fmt.Sprintf("error: tool %s requires confirmation", call.Name), nil
+		}
+		confirmed, err := a.confirm(ctx, call)
+		if err != nil {
+			return "", err
+		}
+		if !confirmed {
+			return fmt.Sprintf("error: tool %s was rejected by the user", call.Name), nil
+		}
+	}
+
+	args := call.Arguments
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	content, err := tool.Execute(ctx, args)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "error: " + err.Error(), nil
+	}
+	return content, nil
+}
@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileCreateTool 是创建文件工具，只能在指定目录下创建文件
+type FileCreateTool struct {
+	dir string
+}
+
+// NewFileCreateTool 创建一个新的创建文件工具，dir 为允许创建文件的根目录
+func NewFileCreateTool(dir string) (Tool, error) {
+	if err := checkDir(dir); err != nil {
+		return nil, err
+	}
+	return &FileCreateTool{dir: dir}, nil
+}
+
+func (t *FileCreateTool) Name() string {
+	return "create_file"
+}
+
+func (t *FileCreateTool) Description() string {
+	return "创建文件工具，在指定目录下创建文件"
+}
+
+func (t *FileCreateTool) Schema() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"name":{"type":"string","description":"文件名，相对于工具的根目录"},"content":{"type":"string","description":"文件内容，可以为空"}},"required":["name"]}`)
+}
+
+// Execute 在根目录下创建文件，文件已存在或路径越出根目录时返回错误
+func (t *FileCreateTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+	if params.Name == "" {
+		return "", errors.New("empty file name")
+	}
+
+	// os.Root 保证路径无法通过 ".." 或符号链接逃出根目录
+	root, err := os.OpenRoot(t.dir)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	file, err := root.OpenFile(params.Name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.WriteString(params.Content); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("created %s (%d bytes)", params.Name, len(params.Content)), nil
+}
+
+// FileDeleteTool 是批量删除文件工具，只能删除指定目录下的文件，执行前必须经过确认
+type FileDeleteTool struct {
+	dir string
+}
+
+// NewFileDeleteTool 创建一个新的批量删除文件工具，dir 为允许删除文件的根目录
+func NewFileDeleteTool(dir string) (Tool, error) {
+	if err := checkDir(dir); err != nil {
+		return nil, err
+	}
+	return &FileDeleteTool{dir: dir}, nil
+}
+
+func (t *FileDeleteTool) Name() string {
+	return "delete_files"
+}
+
+func (t *FileDeleteTool) Description() string {
+	return "批量删除文件工具，批量删除指定路径的文件"
+}
+
+func (t *FileDeleteTool) Schema() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"paths":{"type":"array","items":{"type":"string"},"description":"要删除的文件路径，相对于工具的根目录"}},"required":["paths"]}`)
+}
+
+// Destructive 删除文件不可恢复，需要经过确认
+func (t *FileDeleteTool) Destructive() bool {
+	return true
+}
+
+// Execute 删除根目录下的文件，返回每个路径的处理结果
+func (t *FileDeleteTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Paths []string `json:"paths"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+	if len(params.Paths) == 0 {
+		return "", errors.New("empty paths")
+	}
+
+	root, err := os.OpenRoot(t.dir)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	lines := make([]string, 0, len(params.Paths))
+	for _, path := range params.Paths {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := root.Remove(path); err != nil {
+			lines = append(lines, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		lines = append(lines, path+": deleted")
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// checkDir 检查 dir 是否为已存在的目录
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// HTTPTool 是 http 请求工具，用于请求网页并返回网页内容
+type HTTPTool struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewHTTPTool 创建一个新的 http 请求工具，maxBytes 限制返回内容的最大字节数
+func NewHTTPTool(client *http.Client, maxBytes int64) Tool {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if maxBytes <= 0 {
+		maxBytes = 64 * 1024
+	}
+	return &HTTPTool{
+		client:   client,
+		maxBytes: maxBytes,
+	}
+}
+
+func (t *HTTPTool) Name() string {
+	return "http_request"
+}
+
+func (t *HTTPTool) Description() string {
+	return "http请求工具，用于请求网页，返回网页内容"
+}
+
+func (t *HTTPTool) Schema() json.RawMessage {
+	return json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"要请求的网页地址，仅支持 http 和 https"}},"required":["url"]}`)
+}
+
+// Execute 以 GET 方式请求网页，超出 maxBytes 的内容会被截断
+func (t *HTTPTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+	u, err := url.Parse(params.URL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("only http and https urls are supported")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.maxBytes))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	return string(body), nil
+}
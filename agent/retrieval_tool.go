@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/vectorstore"
+	"slices"
+	"strings"
+)
+
+// RetrievalTool 是向量检索工具，让模型自行决定何时检索知识库
+type RetrievalTool struct {
+	store       vectorstore.VectorStore
+	collections []string
+	maxTopK     int
+}
+
+// NewRetrievalTool 创建一个新的向量检索工具，collections 不为空时只允许检索其中的集合
+func NewRetrievalTool(store vectorstore.VectorStore, collections ...string) (Tool, error) {
+	if store == nil {
+		return nil, errors.New("nil vector store")
+	}
+	return &RetrievalTool{
+		store:       store,
+		collections: collections,
+		maxTopK:     20,
+	}, nil
+}
+
+func (t *RetrievalTool) Name() string {
+	return "vector_search"
+}
+
+func (t *RetrievalTool) Description() string {
+	return "向量检索工具，在知识库集合中检索与查询语义最相近的段落，返回段落 ID、相似度和内容"
+}
+
+func (t *RetrievalTool) Schema() json.RawMessage {
+	collection := map[string]any{
+		"type":        "string",
+		"description": "要检索的集合名称",
+	}
+	if len(t.collections) > 0 {
+		collection["enum"] = t.collections
+	}
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"collection": collection,
+			"query": map[string]any{
+				"type":        "string",
+				"description": "检索的问题或关键词",
+			},
+			"top_k": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("返回的段落数量，默认 5，最大 %d", t.maxTopK),
+			},
+		},
+		"required": []string{"collection", "query"},
+	}
+	bts, _ := json.Marshal(schema)
+	return bts
+}
+
+// Execute 调用 SimilaritySearch 并将结果格式化为文本
+func (t *RetrievalTool) Execute(ctx context.Context, args json.RawMessage) (string, error) {
+	var params struct {
+		Collection string `json:"collection"`
+		Query      string `json:"query"`
+		TopK       int    `json:"top_k"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", err
+	}
+	if params.Collection == "" {
+		return "", errors.New("empty collection")
+	}
+	if len(t.collections) > 0 && !slices.Contains(t.collections, params.Collection) {
+		return "", fmt.Errorf("collection %s is not allowed", params.Collection)
+	}
+	if params.TopK <= 0 {
+		params.TopK = 5
+	}
+	if params.TopK > t.maxTopK {
+		params.TopK = t.maxTopK
+	}
+
+	results, err := t.store.SimilaritySearch(ctx, params.Collection, params.Query, params.TopK)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return "no results", nil
+	}
+	var sb strings.Builder
+	for i, result := range results {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "[%d] id=%s score=%.4f\n", i+1, result.ID, result.Score)
+		sb.WriteString(result.Text)
+	}
+	return sb.String(), nil
+}
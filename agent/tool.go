@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/llm"
+	"sync"
+)
+
+// Tool 是一个可以被模型调用的工具
+type Tool interface {
+	// Name 返回工具名称，在同一个 Registry 中必须唯一
+	Name() string
+	// Description 返回工具的用途说明，供模型决定何时调用
+	Description() string
+	// Schema 返回调用参数的 JSON Schema
+	Schema() json.RawMessage
+	// Execute 使用 JSON 编码的参数执行工具，返回交给模型的结果
+	Execute(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// DestructiveTool 由具有破坏性的工具实现，执行前必须经过确认
+type DestructiveTool interface {
+	Tool
+	Destructive() bool
+}
+
+// Registry 是工具注册表，可以安全地并发使用
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+	order []string
+}
+
+// NewRegistry 创建一个新的工具注册表
+func NewRegistry(tools ...Tool) (*Registry, error) {
+	r := &Registry{tools: make(map[string]Tool)}
+	for _, tool := range tools {
+		if err := r.Register(tool); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register 注册一个工具，名称重复时返回错误
+func (r *Registry) Register(tool Tool) error {
+	if tool == nil {
+		return errors.New("nil tool")
+	}
+	name := tool.Name()
+	if name == "" {
+		return errors.New("empty tool name")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; ok {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	r.tools[name] = tool
+	r.order = append(r.order, name)
+	return nil
+}
+
+// Get 按名称查找工具
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Definitions 按注册顺序返回传给模型的工具定义
+func (r *Registry) Definitions() []llm.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]llm.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tool := r.tools[name]
+		defs = append(defs, llm.Tool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			Parameters:  tool.Schema(),
+		})
+	}
+	return defs
+}
+
+// isDestructive 判断工具是否需要确认后才能执行
+func isDestructive(tool Tool) bool {
+	d, ok := tool.(DestructiveTool)
+	return ok && d.Destructive()
+}
@@ -10,13 +10,37 @@ import (
 )
 
 type TextLoader struct {
-	r io.Reader
+	r              io.Reader
+	copyToMetadata bool
 }
 
-func New(read io.Reader) DocumentLoader {
-	return &TextLoader{
+type Option func(o *TextLoader)
+
+// WithContentMetadata 同时将文本写入元数据的 vectorstore.ContentKey 字段，
+// 用于仍然从元数据读取文本的旧代码，向量存储会因此保存两份文本
+func WithContentMetadata() Option {
+	return func(o *TextLoader) {
+		o.copyToMetadata = true
+	}
+}
+
+func New(read io.Reader, opts ...Option) DocumentLoader {
+	l := &TextLoader{
 		r: read,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// metadata 返回文档的初始元数据
+func (l *TextLoader) metadata(text string) map[string]any {
+	metadata := make(map[string]any)
+	if l.copyToMetadata {
+		metadata[vectorstore.ContentKey] = text
+	}
+	return metadata
 }
 
 func (l *TextLoader) Load() ([]*vectorstore.Document, error) {
@@ -29,11 +53,9 @@ func (l *TextLoader) Load() ([]*vectorstore.Document, error) {
 			continue
 		}
 		docs = append(docs, &vectorstore.Document{
-			Id:   uuid.NewString(),
-			Text: line,
-			Metadata: map[string]any{
-				vectorstore.ContentKey: line,
-			},
+			Id:       uuid.NewString(),
+			Text:     line,
+			Metadata: l.metadata(line),
 		})
 	}
 	if err := scanner.Err(); err != nil {
@@ -53,11 +75,9 @@ func (l *TextLoader) LoadSplit(splitter textsplitter.TextSplitter) ([]*vectorsto
 			continue
 		}
 		docs = append(docs, &vectorstore.Document{
-			Id:   uuid.NewString(),
-			Text: text,
-			Metadata: map[string]any{
-				vectorstore.ContentKey: text,
-			},
+			Id:       uuid.NewString(),
+			Text:     text,
+			Metadata: l.metadata(text),
 		})
 	}
 	return docs, nil
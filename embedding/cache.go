@@ -0,0 +1,396 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cacheMagic 是缓存文件的文件头，用于识别文件格式和版本
+var cacheMagic = [4]byte{'E', 'M', 'B', 1}
+
+// CachedEmbedder 是带本地磁盘缓存的向量嵌入器，相同模型和文本的向量只计算一次
+// 缓存以文件形式存放在 dir 下，按键的前两位十六进制分片，超出容量时淘汰最久未使用的条目
+type CachedEmbedder struct {
+	inner      Embedder
+	model      string
+	dir        string
+	maxEntries int
+	maxBytes   int64
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List // 队首为最近使用的条目
+	bytes     int64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// CacheStats 是缓存的统计信息
+type CacheStats struct {
+	Hits      int64 // 命中次数
+	Misses    int64 // 未命中次数
+	Entries   int   // 当前缓存的条目数
+	Bytes     int64 // 当前缓存占用的字节数
+	Evictions int64 // 被淘汰的条目数
+}
+
+type CacheOption func(c *CachedEmbedder)
+
+// WithCacheMaxEntries 设置缓存最多保存的条目数，0 表示不限制
+func WithCacheMaxEntries(n int) CacheOption {
+	return func(c *CachedEmbedder) {
+		c.maxEntries = n
+	}
+}
+
+// WithCacheMaxBytes 设置缓存最多占用的磁盘字节数，0 表示不限制
+func WithCacheMaxBytes(n int64) CacheOption {
+	return func(c *CachedEmbedder) {
+		c.maxBytes = n
+	}
+}
+
+// NewCachedEmbedder 创建一个新的缓存向量嵌入器，model 为 inner 使用的模型名称，参与缓存键的计算
+func NewCachedEmbedder(inner Embedder, model string, dir string, opts ...CacheOption) (*CachedEmbedder, error) {
+	if inner == nil {
+		return nil, errors.New("nil embedder")
+	}
+	if model == "" {
+		return nil, errors.New("empty model")
+	}
+	c := &CachedEmbedder{
+		inner:   inner,
+		model:   model,
+		dir:     dir,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.maxEntries < 0 || c.maxBytes < 0 {
+		return nil, errors.New("cache limits must be non-negative")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 缓存键区分的嵌入路径，inner 区分查询和文档时同一文本在不同路径下的向量不同
+const (
+	pathPlain    = ""
+	pathQuery    = "query"
+	pathDocument = "document"
+)
+
+// Embed 将单个文本转换为向量嵌入，命中缓存时不会调用 inner
+func (c *CachedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return c.embed(ctx, pathPlain, text, c.inner.Embed)
+}
+
+// Embeds 将多个文本转换为向量嵌入，只把未命中缓存的文本交给 inner
+func (c *CachedEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embeds(ctx, pathPlain, texts, c.inner.Embeds)
+}
+
+// EmbedQuery 将查询文本转换为向量嵌入，inner 区分查询和文档时使用其查询路径
+func (c *CachedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return c.embed(ctx, pathQuery, text, func(ctx context.Context, text string) ([]float32, error) {
+		return EmbedQuery(ctx, c.inner, text)
+	})
+}
+
+// EmbedDocuments 将多个文档文本转换为向量嵌入，inner 区分查询和文档时使用其文档路径
+func (c *CachedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	return c.embeds(ctx, pathDocument, texts, func(ctx context.Context, texts []string) ([][]float32, error) {
+		return EmbedDocuments(ctx, c.inner, texts)
+	})
+}
+
+func (c *CachedEmbedder) embed(ctx context.Context, path string, text string, embedFn func(ctx context.Context, text string) ([]float32, error)) ([]float32, error) {
+	key := c.key(path, text)
+	if embed, ok := c.get(key); ok {
+		return embed, nil
+	}
+	embed, err := embedFn(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.put(key, embed); err != nil {
+		return nil, err
+	}
+	return embed, nil
+}
+
+func (c *CachedEmbedder) embeds(ctx context.Context, path string, texts []string, embedsFn func(ctx context.Context, texts []string) ([][]float32, error)) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, errors.New("empty texts")
+	}
+
+	embeds := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	// 未命中的文本去重后再请求，pending 记录每个文本对应的下标
+	pending := make(map[string][]int)
+	missTexts := make([]string, 0)
+	for i, text := range texts {
+		keys[i] = c.key(path, text)
+		if embed, ok := c.get(keys[i]); ok {
+			embeds[i] = embed
+			continue
+		}
+		if _, ok := pending[keys[i]]; !ok {
+			missTexts = append(missTexts, text)
+		}
+		pending[keys[i]] = append(pending[keys[i]], i)
+	}
+	if len(missTexts) == 0 {
+		return embeds, nil
+	}
+
+	missEmbeds, err := embedsFn(ctx, missTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(missEmbeds) != len(missTexts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(missTexts), len(missEmbeds))
+	}
+	for i, text := range missTexts {
+		key := c.key(path, text)
+		if err := c.put(key, missEmbeds[i]); err != nil {
+			return nil, err
+		}
+		for _, idx := range pending[key] {
+			embeds[idx] = missEmbeds[i]
+		}
+	}
+	return embeds, nil
+}
+
+// Normalized 与 inner 一致
+func (c *CachedEmbedder) Normalized() bool {
+	return IsNormalized(c.inner)
+}
+
+// Model 返回创建时指定的模型名称
+func (c *CachedEmbedder) Model() string {
+	return c.model
+}
+
+// BatchSize 与 inner 一致
+func (c *CachedEmbedder) BatchSize() int {
+	return BatchSize(c.inner)
+}
+
+// Stats 返回缓存的统计信息
+func (c *CachedEmbedder) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Entries:   c.lru.Len(),
+		Bytes:     c.bytes,
+		Evictions: c.evictions,
+	}
+}
+
+// key 根据模型名称、嵌入路径和文本内容计算缓存键，普通路径的键与不区分路径时相同
+func (c *CachedEmbedder) key(path string, text string) string {
+	h := sha256.New()
+	h.Write([]byte(c.model))
+	if path != pathPlain {
+		h.Write([]byte{1})
+		h.Write([]byte(path))
+	}
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// path 返回缓存键对应的文件路径
+func (c *CachedEmbedder) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// get 从缓存读取向量，文件损坏时视为未命中并删除该条目
+func (c *CachedEmbedder) get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.mu.Unlock()
+
+	path := c.path(key)
+	embed, err := readCacheFile(path)
+	if err == nil {
+		// 重启后按修改时间恢复使用顺序，命中时更新修改时间
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.misses++
+		c.remove(key)
+		return nil, false
+	}
+	c.hits++
+	return embed, true
+}
+
+// put 将向量写入缓存，先写临时文件再重命名，保证文件完整
+func (c *CachedEmbedder) put(key string, embed []float32) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data := encodeCacheFile(embed)
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: int64(len(data))})
+	c.bytes += int64(len(data))
+	c.evict()
+	return nil
+}
+
+// evict 淘汰最久未使用的条目直到满足容量限制，调用方需持有锁
+func (c *CachedEmbedder) evict() {
+	for c.lru.Len() > 1 &&
+		((c.maxEntries > 0 && c.lru.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		entry := c.lru.Back().Value.(*cacheEntry)
+		c.remove(entry.key)
+		c.evictions++
+	}
+}
+
+// remove 删除一个条目及其文件，调用方需持有锁
+func (c *CachedEmbedder) remove(key string) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	c.bytes -= elem.Value.(*cacheEntry).size
+	_ = os.Remove(c.path(key))
+}
+
+// load 扫描缓存目录重建索引，按修改时间（最近一次写入或命中的时间）从新到旧排列
+func (c *CachedEmbedder) load() error {
+	type file struct {
+		key   string
+		size  int64
+		mtime int64
+	}
+	files := make([]file, 0)
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name := d.Name()
+		if len(name) != sha256.Size*2 || filepath.Base(filepath.Dir(path)) != name[:2] {
+			// 跳过临时文件和无关文件
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, file{key: name, size: info.Size(), mtime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime > files[j].mtime
+	})
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushBack(&cacheEntry{key: f.key, size: f.size})
+		c.bytes += f.size
+	}
+	c.evict()
+	return nil
+}
+
+// encodeCacheFile 编码缓存文件：文件头、维度、float32 小端序向量
+func encodeCacheFile(embed []float32) []byte {
+	data := make([]byte, 8+4*len(embed))
+	copy(data, cacheMagic[:])
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(embed)))
+	for i, v := range embed {
+		binary.LittleEndian.PutUint32(data[8+4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// readCacheFile 读取并校验缓存文件
+func readCacheFile(path string) ([]float32, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || [4]byte(data[:4]) != cacheMagic {
+		return nil, errors.New("invalid cache file")
+	}
+	n := int(binary.LittleEndian.Uint32(data[4:]))
+	if len(data) != 8+4*n {
+		return nil, errors.New("truncated cache file")
+	}
+	embed := make([]float32, n)
+	for i := range embed {
+		embed[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[8+4*i:]))
+	}
+	return embed, nil
+}
@@ -6,3 +6,29 @@ type Embedder interface {
 	Embed(ctx context.Context, text string) ([]float32, error)
 	Embeds(ctx context.Context, texts []string) ([][]float32, error)
 }
+
+// Modeler 由能报告所用模型名称的向量嵌入器实现
+type Modeler interface {
+	Model() string
+}
+
+// Batcher 由分批请求嵌入服务的向量嵌入器实现，报告每批请求的文本数量
+type Batcher interface {
+	BatchSize() int
+}
+
+// BatchSize 返回 e 每批请求的文本数量，未实现 Batcher 时返回 0
+func BatchSize(e Embedder) int {
+	if b, ok := e.(Batcher); ok {
+		return b.BatchSize()
+	}
+	return 0
+}
+
+// ModelName 返回 e 使用的模型名称，未实现 Modeler 时返回空字符串
+func ModelName(e Embedder) string {
+	if m, ok := e.(Modeler); ok {
+		return m.Model()
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"unicode"
+)
+
+// HashEmbedder 是一个基于字符 n-gram 特征哈希的向量嵌入器
+// 不需要网络和模型，相同输入总是得到相同的向量，适合在测试中代替真实模型。
+// 字符 n-gram 不依赖分词，对中文文本也能得到有意义的相似度排序
+type HashEmbedder struct {
+	dimension int
+	minN      int
+	maxN      int
+}
+
+type HashOption func(o *HashEmbedder)
+
+// WithNGramRange 设置参与哈希的字符 n-gram 长度范围，默认为 1 到 3
+func WithNGramRange(minN, maxN int) HashOption {
+	return func(o *HashEmbedder) {
+		o.minN = minN
+		o.maxN = maxN
+	}
+}
+
+// NewHashEmbedder 创建一个新的特征哈希向量嵌入器，dimension 为向量维度
+func NewHashEmbedder(dimension int, opts ...HashOption) (Embedder, error) {
+	if dimension <= 0 {
+		return nil, errors.New("dimension must be positive")
+	}
+	e := &HashEmbedder{
+		dimension: dimension,
+		minN:      1,
+		maxN:      3,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.minN <= 0 || e.maxN < e.minN {
+		return nil, errors.New("invalid n-gram range")
+	}
+	return e, nil
+}
+
+// Embed 将单个文本转换为 L2 归一化的向量
+// 文本中的字母和数字按 n-gram 切分，每个 n-gram 哈希到一个维度并带上符号，
+// 较长的 n-gram 权重更高，空白和标点被忽略
+func (e *HashEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, unicode.ToLower(r))
+		}
+	}
+	if len(runes) == 0 {
+		return nil, errors.New("empty text")
+	}
+
+	vec := make([]float64, e.dimension)
+	h := fnv.New64a()
+	for n := e.minN; n <= e.maxN; n++ {
+		for i := 0; i+n <= len(runes); i++ {
+			h.Reset()
+			_, _ = h.Write([]byte(string(runes[i : i+n])))
+			sum := h.Sum64()
+			sign := 1.0
+			if sum>>63 == 1 {
+				sign = -1
+			}
+			vec[sum%uint64(e.dimension)] += sign * float64(n)
+		}
+	}
+
+	norm := 0.0
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	embed := make([]float32, e.dimension)
+	if norm == 0 {
+		// 所有特征恰好相互抵消，退化为固定方向，避免零向量
+		embed[0] = 1
+		return embed, nil
+	}
+	for i, v := range vec {
+		embed[i] = float32(v / norm)
+	}
+	return embed, nil
+}
+
+// Embeds 将多个文本转换为向量
+func (e *HashEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, errors.New("empty texts")
+	}
+	embeds := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		embed, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeds = append(embeds, embed)
+	}
+	return embeds, nil
+}
+
+// Normalized 输出的向量总是经过归一化
+func (e *HashEmbedder) Normalized() bool {
+	return true
+}
+
+// Model 返回由维度和 n-gram 范围组成的名称，参数不同的 HashEmbedder 输出的向量不可比较
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d-%d-%d", e.dimension, e.minN, e.maxN)
+}
@@ -0,0 +1,131 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Normalizer 由输出 L2 归一化向量的向量嵌入器实现
+type Normalizer interface {
+	Normalized() bool
+}
+
+// IsNormalized 判断 e 输出的向量是否经过 L2 归一化
+func IsNormalized(e Embedder) bool {
+	n, ok := e.(Normalizer)
+	return ok && n.Normalized()
+}
+
+// NormalizedEmbedder 对 inner 输出的向量做 L2 归一化，
+// 并可以截取前 dimension 维，用于 Matryoshka 训练的模型
+type NormalizedEmbedder struct {
+	inner     Embedder
+	dimension int
+}
+
+// NewNormalizedEmbedder 创建一个新的归一化向量嵌入器，dimension 为 0 时不截断
+func NewNormalizedEmbedder(inner Embedder, dimension int) (QueryDocumentEmbedder, error) {
+	if inner == nil {
+		return nil, errors.New("nil embedder")
+	}
+	if dimension < 0 {
+		return nil, errors.New("dimension must be non-negative")
+	}
+	return &NormalizedEmbedder{
+		inner:     inner,
+		dimension: dimension,
+	}, nil
+}
+
+func (e *NormalizedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	embed, err := e.inner.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return e.normalize(embed)
+}
+
+func (e *NormalizedEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	embeds, err := e.inner.Embeds(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	return e.normalizeAll(embeds)
+}
+
+func (e *NormalizedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	embed, err := EmbedQuery(ctx, e.inner, text)
+	if err != nil {
+		return nil, err
+	}
+	return e.normalize(embed)
+}
+
+func (e *NormalizedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	embeds, err := EmbedDocuments(ctx, e.inner, texts)
+	if err != nil {
+		return nil, err
+	}
+	return e.normalizeAll(embeds)
+}
+
+// Normalized 输出的向量总是经过归一化
+func (e *NormalizedEmbedder) Normalized() bool {
+	return true
+}
+
+// BatchSize 与 inner 一致
+func (e *NormalizedEmbedder) BatchSize() int {
+	return BatchSize(e.inner)
+}
+
+// Model 与 inner 一致，截断维度时附加维度后缀
+func (e *NormalizedEmbedder) Model() string {
+	model := ModelName(e.inner)
+	if model == "" || e.dimension == 0 {
+		return model
+	}
+	return fmt.Sprintf("%s@%d", model, e.dimension)
+}
+
+func (e *NormalizedEmbedder) normalizeAll(embeds [][]float32) ([][]float32, error) {
+	result := make([][]float32, 0, len(embeds))
+	for _, embed := range embeds {
+		normalized, err := e.normalize(embed)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, normalized)
+	}
+	return result, nil
+}
+
+// normalize 截断并归一化向量，返回新的切片
+func (e *NormalizedEmbedder) normalize(embed []float32) ([]float32, error) {
+	if e.dimension > 0 {
+		if len(embed) < e.dimension {
+			return nil, fmt.Errorf("%w: got %d, cannot truncate to %d", ErrDimensionMismatch, len(embed), e.dimension)
+		}
+		embed = embed[:e.dimension]
+	}
+	return Normalize(embed)
+}
+
+// Normalize 返回向量的 L2 归一化结果，零向量返回错误
+func Normalize(embed []float32) ([]float32, error) {
+	norm := 0.0
+	for _, v := range embed {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil, errors.New("vector magnitude cannot be zero")
+	}
+	norm = math.Sqrt(norm)
+	result := make([]float32, len(embed))
+	for i, v := range embed {
+		result[i] = float32(float64(v) / norm)
+	}
+	return result, nil
+}
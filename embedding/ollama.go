@@ -3,27 +3,119 @@ package embedding
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ollama/ollama/api"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
+	"time"
 )
 
+// ErrDimensionMismatch 表示返回的向量维度与期望的维度不一致
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
 // OllamaEmbedder 是一个基于 Ollama 的向量嵌入器
 type OllamaEmbedder struct {
 	client *api.Client
 	model  string
+	// 每个请求最多包含的文本数量
+	batchSize int
+	// 同时进行的最大请求数量
+	concurrency int
+	// 单个请求的超时时间，0 表示不限制
+	timeout time.Duration
+	// 输入超出模型上下文长度时是否截断，nil 表示使用 Ollama 默认行为
+	truncate *bool
+	// 请求结束后模型在内存中保留的时间
+	keepAlive *api.Duration
+	// 传给 Ollama 的模型参数，如 num_ctx
+	options map[string]any
+	// 期望的向量维度，0 表示不检查
+	dimension int
 	// 限制并发数量的信号量
 	sem *semaphore.Weighted
 }
 
+type OllamaOption func(o *OllamaEmbedder)
+
+// WithBatchSize 设置每个请求最多包含的文本数量，默认为 32
+func WithBatchSize(size int) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.batchSize = size
+	}
+}
+
+// WithConcurrency 设置同时进行的最大请求数量，默认为 5
+func WithConcurrency(n int) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.concurrency = n
+	}
+}
+
+// WithTimeout 设置单个请求的超时时间
+func WithTimeout(timeout time.Duration) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.timeout = timeout
+	}
+}
+
+// WithTruncate 设置输入超出模型上下文长度时是否截断，为 false 时超长输入会返回错误
+func WithTruncate(truncate bool) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.truncate = &truncate
+	}
+}
+
+// WithKeepAlive 设置请求结束后模型在内存中保留的时间，负数表示一直保留
+func WithKeepAlive(keepAlive time.Duration) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.keepAlive = &api.Duration{Duration: keepAlive}
+	}
+}
+
+// WithModelOptions 设置传给 Ollama 的模型参数，如 {"num_ctx": 512}
+func WithModelOptions(options map[string]any) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.options = options
+	}
+}
+
+// WithDimension 设置期望的向量维度，返回的向量维度不一致时报错
+func WithDimension(dimension int) OllamaOption {
+	return func(o *OllamaEmbedder) {
+		o.dimension = dimension
+	}
+}
+
 // NewOllamaEmbedder 创建一个新的 Ollama OllamaEmbedder 实例
-func NewOllamaEmbedder(client *api.Client, model string) Embedder {
-	// 默认限制最大并发数为 5
-	return &OllamaEmbedder{
-		client: client,
-		model:  model,
-		sem:    semaphore.NewWeighted(5),
+func NewOllamaEmbedder(client *api.Client, model string, opts ...OllamaOption) Embedder {
+	e := &OllamaEmbedder{
+		client:      client,
+		model:       model,
+		batchSize:   32,
+		concurrency: 5,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	// 非法配置回退为默认值
+	if e.batchSize <= 0 {
+		e.batchSize = 32
+	}
+	if e.concurrency <= 0 {
+		e.concurrency = 5
 	}
+	e.sem = semaphore.NewWeighted(int64(e.concurrency))
+	return e
+}
+
+// Model 返回模型名称
+func (e *OllamaEmbedder) Model() string {
+	return e.model
+}
+
+// BatchSize 返回每批请求的文本数量
+func (e *OllamaEmbedder) BatchSize() int {
+	return e.batchSize
 }
 
 // Embed 将单个文本转换为向量嵌入
@@ -32,41 +124,45 @@ func (e *OllamaEmbedder) Embed(ctx context.Context, text string) ([]float32, err
 		return nil, errors.New("empty text")
 	}
 
-	embed, err := e.client.Embed(ctx, &api.EmbedRequest{
-		Model: e.model,
-		Input: text,
-	})
+	embeds, err := e.embed(ctx, []string{text})
 	if err != nil {
 		return nil, err
 	}
-	if len(embed.Embeddings) == 0 {
-		return nil, errors.New("no embeddings returned")
-	}
-	return embed.Embeddings[0], nil
+	return embeds[0], nil
 }
 
-// Embeds 将多个文本并发转换为向量嵌入，但限制并发数量
+// Embeds 将多个文本分批转换为向量嵌入，每批一个请求，批次之间并发执行但限制并发数量
 func (e *OllamaEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, errors.New("empty texts")
 	}
+	for i, text := range texts {
+		if text == "" {
+			return nil, fmt.Errorf("text %d: empty text", i)
+		}
+	}
 
 	embeds := make([][]float32, len(texts))
 	g, ctx := errgroup.WithContext(ctx)
 
-	for i, text := range texts {
-		i, text := i, text // 创建新的变量以避免闭包问题
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := min(start+e.batchSize, len(texts))
 		if err := e.sem.Acquire(ctx, 1); err != nil {
+			// 等待已启动的批次结束，避免写入 embeds 的 goroutine 泄漏；
+			// ctx 因某个批次失败而取消时返回该批次的错误
+			if werr := g.Wait(); werr != nil {
+				return nil, werr
+			}
 			return nil, err
 		}
 
 		g.Go(func() error {
 			defer e.sem.Release(1)
-			embed, err := e.Embed(ctx, text)
+			batch, err := e.embed(ctx, texts[start:end])
 			if err != nil {
 				return err
 			}
-			embeds[i] = embed
+			copy(embeds[start:end], batch)
 			return nil
 		})
 	}
@@ -77,3 +173,40 @@ func (e *OllamaEmbedder) Embeds(ctx context.Context, texts []string) ([][]float3
 
 	return embeds, nil
 }
+
+// embed 发送一次 /api/embed 请求
+func (e *OllamaEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var input any = texts
+	if len(texts) == 1 {
+		input = texts[0]
+	}
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+	resp, err := e.client.Embed(ctx, &api.EmbedRequest{
+		Model:     e.model,
+		Input:     input,
+		KeepAlive: e.keepAlive,
+		Truncate:  e.truncate,
+		Options:   e.options,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) == 0 {
+		return nil, errors.New("no embeddings returned")
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	if e.dimension > 0 {
+		for _, embed := range resp.Embeddings {
+			if len(embed) != e.dimension {
+				return nil, fmt.Errorf("%w: model %s returned %d, expected %d", ErrDimensionMismatch, e.model, len(embed), e.dimension)
+			}
+		}
+	}
+	return resp.Embeddings, nil
+}
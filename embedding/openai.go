@@ -0,0 +1,182 @@
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAIEmbedder 是兼容 OpenAI /v1/embeddings 协议的向量嵌入器
+type OpenAIEmbedder struct {
+	baseURL   string
+	model     string
+	apiKey    string
+	headers   http.Header
+	client    *http.Client
+	batchSize int
+}
+
+type OpenAIOption func(o *OpenAIEmbedder)
+
+// WithOpenAIAPIKey 设置 Authorization: Bearer 使用的 API Key
+func WithOpenAIAPIKey(key string) OpenAIOption {
+	return func(o *OpenAIEmbedder) {
+		o.apiKey = key
+	}
+}
+
+// WithOpenAIHeader 设置每个请求附带的请求头
+func WithOpenAIHeader(key, value string) OpenAIOption {
+	return func(o *OpenAIEmbedder) {
+		o.headers.Set(key, value)
+	}
+}
+
+// WithOpenAIHTTPClient 设置发送请求使用的 http.Client
+func WithOpenAIHTTPClient(client *http.Client) OpenAIOption {
+	return func(o *OpenAIEmbedder) {
+		o.client = client
+	}
+}
+
+// WithOpenAIBatchSize 设置每个请求最多包含的文本数量，默认为 64
+func WithOpenAIBatchSize(size int) OpenAIOption {
+	return func(o *OpenAIEmbedder) {
+		o.batchSize = size
+	}
+}
+
+// NewOpenAIEmbedder 创建一个新的 OpenAI 兼容向量嵌入器，base 为包含版本前缀的地址，如 http://127.0.0.1:8000/v1
+func NewOpenAIEmbedder(base string, model string, opts ...OpenAIOption) (Embedder, error) {
+	if base == "" {
+		return nil, errors.New("empty base url")
+	}
+	e := &OpenAIEmbedder{
+		baseURL:   strings.TrimRight(base, "/"),
+		model:     model,
+		headers:   make(http.Header),
+		client:    http.DefaultClient,
+		batchSize: 64,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.batchSize <= 0 {
+		return nil, errors.New("batch size must be positive")
+	}
+	return e, nil
+}
+
+// Model 返回模型名称
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+// BatchSize 返回每批请求的文本数量
+func (e *OpenAIEmbedder) BatchSize() int {
+	return e.batchSize
+}
+
+// Embed 将单个文本转换为向量嵌入
+func (e *OpenAIEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, errors.New("empty text")
+	}
+	embeds, err := e.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeds[0], nil
+}
+
+// Embeds 将多个文本分批转换为向量嵌入
+func (e *OpenAIEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, errors.New("empty texts")
+	}
+	embeds := make([][]float32, 0, len(texts))
+	for i := 0; i < len(texts); i += e.batchSize {
+		end := min(i+e.batchSize, len(texts))
+		batch, err := e.embed(ctx, texts[i:end])
+		if err != nil {
+			return nil, err
+		}
+		embeds = append(embeds, batch...)
+	}
+	return embeds, nil
+}
+
+// embed 发送一次 /embeddings 请求，按 index 还原顺序
+func (e *OpenAIEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	bts, err := json.Marshal(map[string]any{
+		"model": e.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(bts))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range e.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Data))
+	}
+	// 数量一致且没有重复的 index 时，每个文本都恰好有一个向量
+	embeds := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		if embeds[item.Index] != nil {
+			return nil, fmt.Errorf("duplicate embedding index %d", item.Index)
+		}
+		if len(item.Embedding) == 0 {
+			return nil, fmt.Errorf("empty embedding at index %d", item.Index)
+		}
+		embeds[item.Index] = item.Embedding
+	}
+	return embeds, nil
+}
+
+// HTTPError 是嵌入服务返回的非 2xx 响应
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("embedding: %d %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,142 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// QueryDocumentEmbedder 是区分查询和文档的向量嵌入器
+// 很多检索模型要求查询和文档使用不同的指令前缀，向量存储在写入文档和检索时分别调用对应的方法
+type QueryDocumentEmbedder interface {
+	Embedder
+	// EmbedQuery 将检索查询转换为向量嵌入
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+	// EmbedDocuments 将待检索的文档转换为向量嵌入
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbedQuery 使用 e 生成查询向量，e 未实现 QueryDocumentEmbedder 时退化为 Embed
+func EmbedQuery(ctx context.Context, e Embedder, text string) ([]float32, error) {
+	if qd, ok := e.(QueryDocumentEmbedder); ok {
+		return qd.EmbedQuery(ctx, text)
+	}
+	return e.Embed(ctx, text)
+}
+
+// EmbedDocuments 使用 e 生成文档向量，e 未实现 QueryDocumentEmbedder 时退化为 Embeds
+func EmbedDocuments(ctx context.Context, e Embedder, texts []string) ([][]float32, error) {
+	if qd, ok := e.(QueryDocumentEmbedder); ok {
+		return qd.EmbedDocuments(ctx, texts)
+	}
+	return e.Embeds(ctx, texts)
+}
+
+// PrefixTemplate 是查询和文档的指令模板
+// 模板中的 {text} 会被替换为原文，不包含 {text} 时模板作为前缀拼接在原文之前，空模板表示不做处理
+type PrefixTemplate struct {
+	Query    string
+	Document string
+}
+
+// DefaultPrefixTemplates 是常用模型的指令模板，键为模型名称的一部分，可以按需增改
+var DefaultPrefixTemplates = map[string]PrefixTemplate{
+	"bge-base-zh":            {Query: "为这个句子生成表示以用于检索相关文章："},
+	"bge-large-zh":           {Query: "为这个句子生成表示以用于检索相关文章："},
+	"bge-small-zh":           {Query: "为这个句子生成表示以用于检索相关文章："},
+	"bge-base-en":            {Query: "Represent this sentence for searching relevant passages: "},
+	"bge-large-en":           {Query: "Represent this sentence for searching relevant passages: "},
+	"bge-small-en":           {Query: "Represent this sentence for searching relevant passages: "},
+	"snowflake-arctic-embed": {Query: "Represent this sentence for searching relevant passages: "},
+	"mxbai-embed-large":      {Query: "Represent this sentence for searching relevant passages: "},
+	"nomic-embed-text":       {Query: "search_query: ", Document: "search_document: "},
+	"multilingual-e5":        {Query: "query: ", Document: "passage: "},
+}
+
+// PrefixTemplateFor 在 DefaultPrefixTemplates 中查找模型对应的模板，
+// 模型名称包含多个键时使用最长的键，如 "quentinz/bge-base-zh-v1.5:latest" 匹配 "bge-base-zh"
+func PrefixTemplateFor(model string) (PrefixTemplate, bool) {
+	best := ""
+	for key := range DefaultPrefixTemplates {
+		if strings.Contains(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return PrefixTemplate{}, false
+	}
+	return DefaultPrefixTemplates[best], true
+}
+
+// apply 将模板应用到文本上
+func apply(template string, text string) string {
+	if template == "" {
+		return text
+	}
+	if strings.Contains(template, "{text}") {
+		return strings.ReplaceAll(template, "{text}", text)
+	}
+	return template + text
+}
+
+// PrefixedEmbedder 为查询和文档分别加上指令前缀后再交给 inner
+// Embed 和 Embeds 不加前缀，直接交给 inner。与 CachedEmbedder 组合时应包在缓存外层，
+// 这样缓存的键是加过前缀的文本
+type PrefixedEmbedder struct {
+	inner    Embedder
+	template PrefixTemplate
+}
+
+// NewPrefixedEmbedder 创建一个新的指令前缀向量嵌入器
+func NewPrefixedEmbedder(inner Embedder, template PrefixTemplate) (QueryDocumentEmbedder, error) {
+	if inner == nil {
+		return nil, errors.New("nil embedder")
+	}
+	return &PrefixedEmbedder{
+		inner:    inner,
+		template: template,
+	}, nil
+}
+
+func (e *PrefixedEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.inner.Embed(ctx, text)
+}
+
+func (e *PrefixedEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.inner.Embeds(ctx, texts)
+}
+
+// EmbedQuery 使用查询模板处理文本后生成向量
+func (e *PrefixedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	if text == "" {
+		return nil, errors.New("empty text")
+	}
+	return e.inner.Embed(ctx, apply(e.template.Query, text))
+}
+
+// EmbedDocuments 使用文档模板处理文本后生成向量
+func (e *PrefixedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, errors.New("empty texts")
+	}
+	prefixed := make([]string, 0, len(texts))
+	for _, text := range texts {
+		prefixed = append(prefixed, apply(e.template.Document, text))
+	}
+	return e.inner.Embeds(ctx, prefixed)
+}
+
+// Normalized 与 inner 一致
+func (e *PrefixedEmbedder) Normalized() bool {
+	return IsNormalized(e.inner)
+}
+
+// Model 与 inner 一致
+func (e *PrefixedEmbedder) Model() string {
+	return ModelName(e.inner)
+}
+
+// BatchSize 与 inner 一致
+func (e *PrefixedEmbedder) BatchSize() int {
+	return BatchSize(e.inner)
+}
@@ -1,24 +1,200 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
 
 type ChatCompletionOptions struct {
 	Messages []Message
-	// ...
+
+	// 以下为生成参数，nil 或零值表示使用后端默认值
+	Model         string         // 覆盖本次调用使用的模型
+	Temperature   *float64       // 采样温度，取值范围 [0, 2]
+	TopP          *float64       // 核采样概率，取值范围 [0, 1]
+	TopK          *int           // 仅从概率最高的 K 个 token 中采样，必须为正数
+	MaxTokens     *int           // 最多生成的 token 数，必须为正数
+	Stop          []string       // 停止序列
+	Seed          *int           // 随机种子，用于复现结果
+	RepeatPenalty *float64       // 重复惩罚系数，必须为正数
+	KeepAlive     *time.Duration // 调用结束后模型在内存中保留的时间
+
+	// Tools 是模型可以调用的工具列表
+	Tools []Tool
+
+	// ResponseFormat 要求模型以 JSON 格式回复，nil 表示不限制
+	ResponseFormat *ResponseFormat
+}
+
+// ResponseFormat 描述模型回复的 JSON 格式
+type ResponseFormat struct {
+	// Schema 是回复需要符合的 JSON Schema，为空时只要求回复为合法的 JSON
+	Schema json.RawMessage
+}
+
+// JSONSchemaFormat 根据 Go 类型生成 ResponseFormat，规则见 SchemaOf
+func JSONSchemaFormat(v any) (*ResponseFormat, error) {
+	schema, err := SchemaOf(v)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseFormat{Schema: schema}, nil
+}
+
+// Validate 检查生成参数是否在合法范围内
+func (o ChatCompletionOptions) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return errors.New("temperature must be in [0, 2]")
+	}
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1) {
+		return errors.New("top_p must be in [0, 1]")
+	}
+	if o.TopK != nil && *o.TopK <= 0 {
+		return errors.New("top_k must be positive")
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return errors.New("max tokens must be positive")
+	}
+	if o.RepeatPenalty != nil && *o.RepeatPenalty <= 0 {
+		return errors.New("repeat penalty must be positive")
+	}
+	for _, stop := range o.Stop {
+		if stop == "" {
+			return errors.New("stop sequence cannot be empty")
+		}
+	}
+	for i, tool := range o.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tool %d: empty name", i)
+		}
+		if len(tool.Parameters) > 0 && !json.Valid(tool.Parameters) {
+			return fmt.Errorf("tool %s: invalid parameters schema", tool.Name)
+		}
+	}
+	if o.ResponseFormat != nil && len(o.ResponseFormat.Schema) > 0 && !json.Valid(o.ResponseFormat.Schema) {
+		return errors.New("invalid response format schema")
+	}
+	return nil
+}
+
+// SetOptions 返回本次调用显式设置的生成参数名称
+func (o ChatCompletionOptions) SetOptions() []string {
+	var names []string
+	if o.Model != "" {
+		names = append(names, OptionModel)
+	}
+	if o.Temperature != nil {
+		names = append(names, OptionTemperature)
+	}
+	if o.TopP != nil {
+		names = append(names, OptionTopP)
+	}
+	if o.TopK != nil {
+		names = append(names, OptionTopK)
+	}
+	if o.MaxTokens != nil {
+		names = append(names, OptionMaxTokens)
+	}
+	if len(o.Stop) > 0 {
+		names = append(names, OptionStop)
+	}
+	if o.Seed != nil {
+		names = append(names, OptionSeed)
+	}
+	if o.RepeatPenalty != nil {
+		names = append(names, OptionRepeatPenalty)
+	}
+	if o.KeepAlive != nil {
+		names = append(names, OptionKeepAlive)
+	}
+	return names
+}
+
+// 生成参数名称，用于报告后端忽略了哪些参数
+const (
+	OptionModel         = "model"
+	OptionTemperature   = "temperature"
+	OptionTopP          = "top_p"
+	OptionTopK          = "top_k"
+	OptionMaxTokens     = "max_tokens"
+	OptionStop          = "stop"
+	OptionSeed          = "seed"
+	OptionRepeatPenalty = "repeat_penalty"
+	OptionKeepAlive     = "keep_alive"
+)
+
+// Ptr 返回 v 的指针，便于设置可选的生成参数
+func Ptr[T any](v T) *T {
+	return &v
 }
 
 const (
 	RoleUser      = "user"
 	RoleAssistant = "assistant"
 	RoleSystem    = "system"
+	RoleTool      = "tool"
 )
 
 type Message struct {
-	Role    string // "user", "assistant", "system"
-	Content string
+	Role       string // "user", "assistant", "system", "tool"
+	Content    string
+	ToolCalls  []ToolCall // 模型请求的工具调用，仅 assistant 消息有值
+	ToolCallID string     // 工具结果对应的调用 ID，仅 tool 消息有值
+	Name       string     // 工具名称，仅 tool 消息有值
+}
+
+// Tool 描述一个可供模型调用的工具
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
+}
+
+// ToolCall 是模型发起的一次工具调用
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage // JSON 编码的调用参数
+}
+
+// NewToolMessage 创建一条工具结果消息，用于将工具的执行结果回传给模型
+func NewToolMessage(call ToolCall, content string) Message {
+	return Message{
+		Role:       RoleTool,
+		Content:    content,
+		ToolCallID: call.ID,
+		Name:       call.Name,
+	}
+}
+
+// FinishReasonToolCalls 表示模型因请求工具调用而结束生成
+const FinishReasonToolCalls = "tool_calls"
+
+// ChatCompletionResponse 是一次对话的完整回复
+type ChatCompletionResponse struct {
+	Message      Message // 回复消息，可能包含工具调用
+	FinishReason string  // 结束原因，如 "stop"、"length"、"tool_calls"
 }
 
+// ChatCompletionChunk 是流式对话返回的一个分片
+type ChatCompletionChunk struct {
+	Delta        string   // 本次新增的内容
+	Done         bool     // 是否为最后一个分片
+	FinishReason string   // 结束原因，仅在 Done 为 true 时有值，如 "stop"、"length"
+	Message      *Message // 完整的回复消息，仅在 Done 为 true 时有值
+}
+
+// ChatCompletionStreamFunc 在收到每个分片时被调用，返回错误会中止流式输出
+type ChatCompletionStreamFunc func(chunk *ChatCompletionChunk) error
+
 type LLM interface {
-	ChatCompletion(ctx context.Context, opts ChatCompletionOptions) (string, error)
+	ChatCompletion(ctx context.Context, opts ChatCompletionOptions) (*ChatCompletionResponse, error)
+	// ChatCompletionStream 以流式方式生成回复，分片依次交给 fn 处理
+	ChatCompletionStream(ctx context.Context, opts ChatCompletionOptions, fn ChatCompletionStreamFunc) error
+	// IgnoredOptions 返回 opts 中已设置但该后端不支持而被忽略的生成参数名称
+	IgnoredOptions(opts ChatCompletionOptions) []string
 	CreateEmbedding(ctx context.Context, text string) ([]float32, error)
 }
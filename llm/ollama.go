@@ -2,10 +2,13 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ollama/ollama/api"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 type OllamaLLM struct {
@@ -24,9 +27,83 @@ func New(base string, model string) (LLM, error) {
 	}, nil
 }
 
-func (l *OllamaLLM) ChatCompletion(ctx context.Context, opts ChatCompletionOptions) (string, error) {
-	//TODO implement me
-	panic("implement me")
+// ChatCompletion 调用 Ollama 的 /api/chat 接口，返回完整的回复内容
+func (l *OllamaLLM) ChatCompletion(ctx context.Context, opts ChatCompletionOptions) (*ChatCompletionResponse, error) {
+	reply := &ollamaReply{}
+	err := l.chat(ctx, opts, false, func(resp api.ChatResponse) error {
+		reply.add(resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.response(), nil
+}
+
+// ChatCompletionStream 以流式方式调用 Ollama 的 /api/chat 接口
+func (l *OllamaLLM) ChatCompletionStream(ctx context.Context, opts ChatCompletionOptions, fn ChatCompletionStreamFunc) error {
+	if fn == nil {
+		return errors.New("nil stream func")
+	}
+	reply := &ollamaReply{}
+	return l.chat(ctx, opts, true, func(resp api.ChatResponse) error {
+		reply.add(resp)
+		chunk := &ChatCompletionChunk{
+			Delta: resp.Message.Content,
+			Done:  resp.Done,
+		}
+		if resp.Done {
+			final := reply.response()
+			chunk.FinishReason = final.FinishReason
+			chunk.Message = &final.Message
+		}
+		return fn(chunk)
+	})
+}
+
+// chat 构建 Ollama 聊天请求并发送，每个响应都交给 fn 处理
+func (l *OllamaLLM) chat(ctx context.Context, opts ChatCompletionOptions, stream bool, fn api.ChatResponseFunc) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	messages, err := toOllamaMessages(opts.Messages)
+	if err != nil {
+		return err
+	}
+
+	req := &api.ChatRequest{
+		Model:    l.model,
+		Messages: messages,
+		Stream:   &stream,
+		Options:  toOllamaOptions(opts),
+	}
+	if len(opts.Tools) > 0 {
+		if req.Tools, err = toOllamaTools(opts.Tools); err != nil {
+			return err
+		}
+	}
+	if opts.Model != "" {
+		req.Model = opts.Model
+	}
+	if opts.KeepAlive != nil {
+		req.KeepAlive = &api.Duration{Duration: *opts.KeepAlive}
+	}
+	if opts.ResponseFormat != nil {
+		req.Format = json.RawMessage(`"json"`)
+		if len(opts.ResponseFormat.Schema) > 0 {
+			req.Format = opts.ResponseFormat.Schema
+		}
+	}
+	if err := l.client.Chat(ctx, req, fn); err != nil {
+		return err
+	}
+	// 连接被取消时 Chat 可能返回 nil，这里再检查一次 ctx
+	return ctx.Err()
+}
+
+// IgnoredOptions Ollama 支持全部生成参数，不会忽略任何参数
+func (l *OllamaLLM) IgnoredOptions(opts ChatCompletionOptions) []string {
+	return nil
 }
 
 func (l *OllamaLLM) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
@@ -42,3 +119,130 @@ func (l *OllamaLLM) CreateEmbedding(ctx context.Context, text string) ([]float32
 	}
 	return embed.Embeddings[0], nil
 }
+
+// toOllamaMessages 将 Message 转换为 Ollama 的聊天消息
+func toOllamaMessages(msgs []Message) ([]api.Message, error) {
+	if len(msgs) == 0 {
+		return nil, errors.New("empty messages")
+	}
+	messages := make([]api.Message, 0, len(msgs))
+	for i, msg := range msgs {
+		switch msg.Role {
+		case RoleUser, RoleAssistant, RoleSystem, RoleTool:
+		default:
+			return nil, fmt.Errorf("message %d: unknown role %q", i, msg.Role)
+		}
+		message := api.Message{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		for _, call := range msg.ToolCalls {
+			args := api.ToolCallFunctionArguments{}
+			if len(call.Arguments) > 0 {
+				if err := json.Unmarshal(call.Arguments, &args); err != nil {
+					return nil, fmt.Errorf("message %d: tool call %s: %w", i, call.Name, err)
+				}
+			}
+			message.ToolCalls = append(message.ToolCalls, api.ToolCall{
+				Function: api.ToolCallFunction{
+					Name:      call.Name,
+					Arguments: args,
+				},
+			})
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// toOllamaTools 将 Tool 转换为 Ollama 的工具定义
+func toOllamaTools(tools []Tool) (api.Tools, error) {
+	result := make(api.Tools, 0, len(tools))
+	for _, tool := range tools {
+		function := api.ToolFunction{
+			Name:        tool.Name,
+			Description: tool.Description,
+		}
+		if len(tool.Parameters) > 0 {
+			if err := json.Unmarshal(tool.Parameters, &function.Parameters); err != nil {
+				return nil, fmt.Errorf("tool %s: %w", tool.Name, err)
+			}
+		}
+		if function.Parameters.Type == "" {
+			function.Parameters.Type = "object"
+		}
+		result = append(result, api.Tool{
+			Type:     "function",
+			Function: function,
+		})
+	}
+	return result, nil
+}
+
+// ollamaReply 汇总 Ollama 返回的一个或多个响应
+type ollamaReply struct {
+	content    strings.Builder
+	toolCalls  []ToolCall
+	doneReason string
+}
+
+func (r *ollamaReply) add(resp api.ChatResponse) {
+	r.content.WriteString(resp.Message.Content)
+	for _, call := range resp.Message.ToolCalls {
+		args, _ := json.Marshal(call.Function.Arguments)
+		// Ollama 不返回调用 ID，这里按顺序生成
+		r.toolCalls = append(r.toolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", len(r.toolCalls)),
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+	if resp.Done {
+		r.doneReason = resp.DoneReason
+	}
+}
+
+func (r *ollamaReply) response() *ChatCompletionResponse {
+	resp := &ChatCompletionResponse{
+		Message: Message{
+			Role:      RoleAssistant,
+			Content:   r.content.String(),
+			ToolCalls: r.toolCalls,
+		},
+		FinishReason: r.doneReason,
+	}
+	if len(r.toolCalls) > 0 {
+		resp.FinishReason = FinishReasonToolCalls
+	}
+	return resp
+}
+
+// toOllamaOptions 将生成参数转换为 Ollama 的 options
+func toOllamaOptions(opts ChatCompletionOptions) map[string]any {
+	options := make(map[string]any)
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if opts.TopK != nil {
+		options["top_k"] = *opts.TopK
+	}
+	if opts.MaxTokens != nil {
+		options["num_predict"] = *opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+	if opts.RepeatPenalty != nil {
+		options["repeat_penalty"] = *opts.RepeatPenalty
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
@@ -0,0 +1,407 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OpenAILLM 是兼容 OpenAI /v1/chat/completions 和 /v1/embeddings 协议的客户端，
+// 适用于 vLLM、llama.cpp server、LM Studio 等服务
+type OpenAILLM struct {
+	baseURL string
+	model   string
+	apiKey  string
+	headers http.Header
+	client  *http.Client
+}
+
+type OpenAIOption func(o *OpenAILLM)
+
+// WithAPIKey 设置 Authorization: Bearer 使用的 API Key
+func WithAPIKey(key string) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.apiKey = key
+	}
+}
+
+// WithHeader 设置每个请求附带的请求头
+func WithHeader(key, value string) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.headers.Set(key, value)
+	}
+}
+
+// WithHTTPClient 设置发送请求使用的 http.Client
+func WithHTTPClient(client *http.Client) OpenAIOption {
+	return func(o *OpenAILLM) {
+		o.client = client
+	}
+}
+
+// NewOpenAI 创建一个新的 OpenAI 兼容客户端，base 为包含版本前缀的地址，如 http://127.0.0.1:8000/v1
+func NewOpenAI(base string, model string, opts ...OpenAIOption) (LLM, error) {
+	if base == "" {
+		return nil, errors.New("empty base url")
+	}
+	l := &OpenAILLM{
+		baseURL: strings.TrimRight(base, "/"),
+		model:   model,
+		headers: make(http.Header),
+		client:  http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+// OpenAIError 是 OpenAI 兼容服务返回的错误
+type OpenAIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *OpenAIError) Error() string {
+	return fmt.Sprintf("openai: %d %s", e.StatusCode, e.Message)
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	Name       string           `json:"name,omitempty"`
+}
+
+type openAIToolCall struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description,omitempty"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIChatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []openAIMessage `json:"messages"`
+	Stream         bool            `json:"stream,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	TopP           *float64        `json:"top_p,omitempty"`
+	MaxTokens      *int            `json:"max_tokens,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	Seed           *int            `json:"seed,omitempty"`
+	Tools          []openAITool    `json:"tools,omitempty"`
+	ResponseFormat any             `json:"response_format,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// ChatCompletion 调用 /chat/completions 接口，返回完整的回复内容
+func (l *OpenAILLM) ChatCompletion(ctx context.Context, opts ChatCompletionOptions) (*ChatCompletionResponse, error) {
+	req, err := l.chatRequest(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := l.post(ctx, "/chat/completions", req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var resp openAIChatResponse
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("no choices returned")
+	}
+	choice := resp.Choices[0]
+	return &ChatCompletionResponse{
+		Message:      fromOpenAIMessage(choice.Message),
+		FinishReason: choice.FinishReason,
+	}, nil
+}
+
+// ChatCompletionStream 以 SSE 流式方式调用 /chat/completions 接口
+func (l *OpenAILLM) ChatCompletionStream(ctx context.Context, opts ChatCompletionOptions, fn ChatCompletionStreamFunc) error {
+	if fn == nil {
+		return errors.New("nil stream func")
+	}
+	req, err := l.chatRequest(opts, true)
+	if err != nil {
+		return err
+	}
+	body, err := l.post(ctx, "/chat/completions", req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var content strings.Builder
+	var calls []openAIToolCall
+	finishReason := ""
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var resp openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &resp); err != nil {
+			return err
+		}
+		if len(resp.Choices) == 0 {
+			continue
+		}
+		choice := resp.Choices[0]
+		content.WriteString(choice.Delta.Content)
+		if calls, err = mergeToolCallDeltas(calls, choice.Delta.ToolCalls); err != nil {
+			return err
+		}
+		if choice.FinishReason != "" {
+			finishReason = choice.FinishReason
+		}
+		if choice.Delta.Content == "" {
+			continue
+		}
+		if err := fn(&ChatCompletionChunk{Delta: choice.Delta.Content}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	message := fromOpenAIMessage(openAIMessage{
+		Role:      RoleAssistant,
+		Content:   content.String(),
+		ToolCalls: calls,
+	})
+	return fn(&ChatCompletionChunk{
+		Done:         true,
+		FinishReason: finishReason,
+		Message:      &message,
+	})
+}
+
+// IgnoredOptions OpenAI 协议不支持 top_k、repeat_penalty 和 keep_alive
+func (l *OpenAILLM) IgnoredOptions(opts ChatCompletionOptions) []string {
+	var ignored []string
+	for _, name := range opts.SetOptions() {
+		switch name {
+		case OptionTopK, OptionRepeatPenalty, OptionKeepAlive:
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored
+}
+
+// CreateEmbedding 调用 /embeddings 接口生成向量嵌入
+func (l *OpenAILLM) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	body, err := l.post(ctx, "/embeddings", map[string]any{
+		"model": l.model,
+		"input": []string{text},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var resp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, errors.New("no embeddings")
+	}
+	return resp.Data[0].Embedding, nil
+}
+
+// chatRequest 将 ChatCompletionOptions 转换为 OpenAI 请求
+func (l *OpenAILLM) chatRequest(opts ChatCompletionOptions, stream bool) (*openAIChatRequest, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if len(opts.Messages) == 0 {
+		return nil, errors.New("empty messages")
+	}
+	req := &openAIChatRequest{
+		Model:       l.model,
+		Stream:      stream,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		MaxTokens:   opts.MaxTokens,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
+	}
+	if opts.Model != "" {
+		req.Model = opts.Model
+	}
+	for i, msg := range opts.Messages {
+		switch msg.Role {
+		case RoleUser, RoleAssistant, RoleSystem, RoleTool:
+		default:
+			return nil, fmt.Errorf("message %d: unknown role %q", i, msg.Role)
+		}
+		message := openAIMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+			Name:       msg.Name,
+		}
+		for j, call := range msg.ToolCalls {
+			c := openAIToolCall{Index: j, ID: call.ID, Type: "function"}
+			c.Function.Name = call.Name
+			c.Function.Arguments = string(call.Arguments)
+			message.ToolCalls = append(message.ToolCalls, c)
+		}
+		req.Messages = append(req.Messages, message)
+	}
+	for _, tool := range opts.Tools {
+		t := openAITool{Type: "function"}
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		req.Tools = append(req.Tools, t)
+	}
+	if opts.ResponseFormat != nil {
+		if len(opts.ResponseFormat.Schema) > 0 {
+			req.ResponseFormat = map[string]any{
+				"type": "json_schema",
+				"json_schema": map[string]any{
+					"name":   "response",
+					"schema": opts.ResponseFormat.Schema,
+				},
+			}
+		} else {
+			req.ResponseFormat = map[string]any{"type": "json_object"}
+		}
+	}
+	return req, nil
+}
+
+// post 发送 JSON 请求，状态码不是 2xx 时返回 *OpenAIError
+func (l *OpenAILLM) post(ctx context.Context, path string, data any) (io.ReadCloser, error) {
+	bts, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.baseURL+path, bytes.NewReader(bts))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range l.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+l.apiKey)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, readOpenAIError(resp)
+	}
+	return resp.Body, nil
+}
+
+// readOpenAIError 从错误响应中读取错误信息
+func readOpenAIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
+		message = errResp.Error.Message
+	}
+	return &OpenAIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+// fromOpenAIMessage 将 OpenAI 消息转换为 Message
+func fromOpenAIMessage(msg openAIMessage) Message {
+	message := Message{
+		Role:    RoleAssistant,
+		Content: msg.Content,
+	}
+	for _, call := range msg.ToolCalls {
+		args := json.RawMessage(call.Function.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+	return message
+}
+
+// mergeToolCallDeltas 按 index 合并流式返回的工具调用片段
+// index 只能指向已有的工具调用或紧随其后的新调用，否则返回错误
+func mergeToolCallDeltas(calls []openAIToolCall, deltas []openAIToolCall) ([]openAIToolCall, error) {
+	for _, delta := range deltas {
+		if delta.Index < 0 || delta.Index > len(calls) {
+			return nil, fmt.Errorf("tool call index %d out of range", delta.Index)
+		}
+		if delta.Index == len(calls) {
+			calls = append(calls, openAIToolCall{Index: len(calls)})
+		}
+		call := &calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return calls, nil
+}
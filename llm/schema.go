@@ -0,0 +1,366 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaOf 根据 Go 类型生成 JSON Schema
+// 结构体字段使用 json 标签作为属性名，未标记 omitempty 的字段为必填，
+// description 标签会作为属性说明
+func SchemaOf(v any) (json.RawMessage, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, errors.New("nil value")
+	}
+	schema, err := schemaOfType(t, make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema)
+}
+
+func schemaOfType(t reflect.Type, seen map[reflect.Type]bool) (map[string]any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}, nil
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return map[string]any{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// 与 encoding/json 一致，[]byte 编码为 base64 字符串
+			return map[string]any{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := schemaOfType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaOfType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := make(map[string]any)
+		required := make([]string, 0)
+		for _, field := range jsonFields(t) {
+			prop, err := schemaOfType(field.typ, seen)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field.path, err)
+			}
+			if typ, ok := prop["type"].(string); ok && field.typ.Kind() == reflect.Pointer {
+				// 指针字段允许为 null
+				prop["type"] = []string{typ, "null"}
+			}
+			if field.description != "" {
+				prop["description"] = field.description
+			}
+			properties[field.name] = prop
+			if field.required {
+				required = append(required, field.name)
+			}
+		}
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// jsonField 是结构体序列化为 JSON 时的一个属性
+type jsonField struct {
+	name        string
+	path        string // 字段在 Go 结构体中的路径，用于错误信息
+	typ         reflect.Type
+	description string
+	required    bool
+	tagged      bool
+	depth       int
+}
+
+// jsonFields 按 encoding/json 的规则列出结构体的属性：
+// 没有 json 名称的匿名结构体字段会被展开，同名属性取嵌入层级最浅的，
+// 同一层级有多个时取唯一带 json 名称的，否则都忽略
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	var collect func(t reflect.Type, path string, depth int, optional bool, visited map[reflect.Type]bool)
+	collect = func(t reflect.Type, path string, depth int, optional bool, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, omitempty, skip := parseJSONTag(field)
+			if skip {
+				continue
+			}
+			ft := field.Type
+			if field.Anonymous {
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !field.IsExported() && ft.Kind() != reflect.Struct {
+					continue
+				}
+				if ft.Kind() == reflect.Struct && !hasJSONName(field) {
+					// 嵌入的结构体指针为 nil 时其字段不会输出，展开的字段都不是必填
+					collect(ft, path+field.Name+".", depth+1, optional || field.Type.Kind() == reflect.Pointer, visited)
+					continue
+				}
+			} else if !field.IsExported() {
+				continue
+			}
+			fields = append(fields, jsonField{
+				name:        name,
+				path:        path + field.Name,
+				typ:         field.Type,
+				description: field.Tag.Get("description"),
+				required:    !omitempty && !optional,
+				tagged:      hasJSONName(field),
+				depth:       depth,
+			})
+		}
+	}
+	collect(t, "", 0, false, make(map[reflect.Type]bool))
+
+	byName := make(map[string][]int)
+	for i, f := range fields {
+		byName[f.name] = append(byName[f.name], i)
+	}
+	result := make([]jsonField, 0, len(fields))
+	for i, f := range fields {
+		if dominant, ok := dominantField(fields, byName[f.name]); ok && dominant == i {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// dominantField 从同名属性中选出生效的一个
+func dominantField(fields []jsonField, candidates []int) (int, bool) {
+	depth := fields[candidates[0]].depth
+	for _, i := range candidates {
+		depth = min(depth, fields[i].depth)
+	}
+	var shallowest, tagged []int
+	for _, i := range candidates {
+		if fields[i].depth == depth {
+			shallowest = append(shallowest, i)
+			if fields[i].tagged {
+				tagged = append(tagged, i)
+			}
+		}
+	}
+	switch {
+	case len(shallowest) == 1:
+		return shallowest[0], true
+	case len(tagged) == 1:
+		return tagged[0], true
+	}
+	return 0, false
+}
+
+// hasJSONName 返回字段的 json 标签是否指定了名称
+func hasJSONName(field reflect.StructField) bool {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return name != ""
+}
+
+// parseJSONTag 解析字段的 json 标签
+func parseJSONTag(field reflect.StructField) (name string, omitempty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
+	}
+	return name, omitempty, false
+}
+
+// ValidateJSON 使用 JSON Schema 校验 JSON 数据
+// 支持 type、properties、required、additionalProperties、items、enum、
+// minimum、maximum、minLength、maxLength、minItems、maxItems 关键字
+func ValidateJSON(schema json.RawMessage, data []byte) error {
+	var s any
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return validateValue(s, v, "$")
+}
+
+func validateValue(schema any, v any, path string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		// true 或空 schema 接受任意值
+		return nil
+	}
+
+	if types, ok := s["type"]; ok && !matchType(types, v) {
+		return fmt.Errorf("%s: expected type %v", path, types)
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value must be one of %v", path, enum)
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		n := float64(len([]rune(val)))
+		if min, ok := s["minLength"].(float64); ok && n < min {
+			return fmt.Errorf("%s: length must be >= %v", path, min)
+		}
+		if max, ok := s["maxLength"].(float64); ok && n > max {
+			return fmt.Errorf("%s: length must be <= %v", path, max)
+		}
+	case float64:
+		if min, ok := s["minimum"].(float64); ok && val < min {
+			return fmt.Errorf("%s: must be >= %v", path, min)
+		}
+		if max, ok := s["maximum"].(float64); ok && val > max {
+			return fmt.Errorf("%s: must be <= %v", path, max)
+		}
+	case []any:
+		n := float64(len(val))
+		if min, ok := s["minItems"].(float64); ok && n < min {
+			return fmt.Errorf("%s: must have >= %v items", path, min)
+		}
+		if max, ok := s["maxItems"].(float64); ok && n > max {
+			return fmt.Errorf("%s: must have <= %v items", path, max)
+		}
+		if items, ok := s["items"]; ok {
+			for i, item := range val {
+				if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if required, ok := s["required"].([]any); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, ok := val[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		properties, _ := s["properties"].(map[string]any)
+		for name, item := range val {
+			if prop, ok := properties[name]; ok {
+				if err := validateValue(prop, item, path+"."+name); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s: unexpected property %q", path, name)
+				}
+			case map[string]any:
+				if err := validateValue(additional, item, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchType 判断值是否符合 type 关键字，type 可以是字符串或字符串数组
+func matchType(types any, v any) bool {
+	switch t := types.(type) {
+	case string:
+		return matchSingleType(t, v)
+	case []any:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchSingleType(name, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchSingleType(t string, v any) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChatCompletionJSON 要求模型按 JSON Schema 回复，并将回复解析为 T
+// opts.ResponseFormat 为 nil 时根据 T 生成 Schema；
+// 回复不是合法 JSON 或不符合 Schema 时，会把错误反馈给模型并重新请求，最多重试 maxRetries 次
+func ChatCompletionJSON[T any](ctx context.Context, l LLM, opts ChatCompletionOptions, maxRetries int) (T, error) {
+	var zero T
+	if maxRetries < 0 {
+		return zero, errors.New("max retries must be non-negative")
+	}
+	if opts.ResponseFormat == nil {
+		format, err := JSONSchemaFormat(zero)
+		if err != nil {
+			return zero, err
+		}
+		opts.ResponseFormat = format
+	}
+	schema := opts.ResponseFormat.Schema
+	messages := append([]Message(nil), opts.Messages...)
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		opts.Messages = messages
+		resp, err := l.ChatCompletion(ctx, opts)
+		if err != nil {
+			return zero, err
+		}
+
+		// 每次重试都解析到新的值，避免上一次解析了一半的字段残留
+		var result T
+		content := stripCodeFence(resp.Message.Content)
+		lastErr = decodeJSON(schema, content, &result)
+		if lastErr == nil {
+			return result, nil
+		}
+		messages = append(messages, resp.Message, Message{
+			Role:    RoleUser,
+			Content: fmt.Sprintf("你的回复不符合要求：%v。请只返回符合 JSON Schema 的 JSON：%s", lastErr, schema),
+		})
+	}
+	return zero, fmt.Errorf("invalid json after %d attempts: %w", maxRetries+1, lastErr)
+}
+
+// decodeJSON 校验并解析 JSON
+func decodeJSON(schema json.RawMessage, content string, v any) error {
+	if !json.Valid([]byte(content)) {
+		return errors.New("reply is not valid json")
+	}
+	if len(schema) > 0 {
+		if err := ValidateJSON(schema, []byte(content)); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal([]byte(content), v)
+}
+
+// stripCodeFence 去除模型回复中可能包裹 JSON 的 markdown 代码块
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimPrefix(content, "json")
+	content = strings.TrimSuffix(content, "```")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,121 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 表示熔断器处于打开状态，调用被直接拒绝
+var ErrCircuitOpen = errors.New("resilience: circuit breaker is open")
+
+// State 是熔断器的状态
+type State int
+
+const (
+	StateClosed   State = iota // 正常放行
+	StateOpen                  // 拒绝所有调用
+	StateHalfOpen              // 冷却结束，放行一次试探调用
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker 是一个基于连续失败次数的熔断器
+// 连续失败达到 threshold 次后打开，经过 cooldown 后进入半开状态放行一次试探调用，
+// 试探成功则关闭，失败则重新打开
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker 创建一个新的熔断器
+func NewBreaker(threshold int, cooldown time.Duration) (*Breaker, error) {
+	if threshold <= 0 {
+		return nil, errors.New("threshold must be positive")
+	}
+	if cooldown <= 0 {
+		return nil, errors.New("cooldown must be positive")
+	}
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}, nil
+}
+
+// State 返回熔断器当前的状态
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow 判断是否放行本次调用
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		// 半开状态下同一时间只放行一次试探调用
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Success 记录一次成功的调用
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Release 结束一次既不算成功也不算失败的调用，例如调用方取消或请求本身有误，
+// 只释放半开状态的试探名额，不改变状态和连续失败次数
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Failure 记录一次失败的调用
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
@@ -0,0 +1,228 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/llm"
+	"github.com/ollama/ollama/api"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Executor 按重试策略和熔断器执行调用，可以安全地并发使用
+type Executor struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	multiplier  float64
+	classify    func(err error) bool
+	breaker     *Breaker
+	observer    func(report Report)
+}
+
+// Report 是一次调用的执行报告
+type Report struct {
+	Op       string        // 操作名称，如 "Embed"、"ChatCompletion"
+	Attempts int           // 实际尝试的次数
+	Duration time.Duration // 包含重试等待在内的总耗时
+	Err      error         // 最终的错误，成功时为 nil
+}
+
+type Option func(e *Executor)
+
+// WithMaxAttempts 设置最多尝试的次数（包括第一次），默认为 4
+func WithMaxAttempts(n int) Option {
+	return func(e *Executor) {
+		e.maxAttempts = n
+	}
+}
+
+// WithBackoff 设置指数退避的初始等待时间、最大等待时间和增长倍数，默认为 200ms、10s、2
+func WithBackoff(base, max time.Duration, multiplier float64) Option {
+	return func(e *Executor) {
+		e.baseDelay = base
+		e.maxDelay = max
+		e.multiplier = multiplier
+	}
+}
+
+// WithClassifier 设置判断错误是否可重试的函数，默认为 IsRetryable
+func WithClassifier(classify func(err error) bool) Option {
+	return func(e *Executor) {
+		e.classify = classify
+	}
+}
+
+// WithCircuitBreaker 设置熔断器，多个 Executor 可以共享同一个熔断器
+func WithCircuitBreaker(breaker *Breaker) Option {
+	return func(e *Executor) {
+		e.breaker = breaker
+	}
+}
+
+// WithObserver 设置每次调用结束后接收执行报告的函数，并发调用时 observer 也会被并发调用
+func WithObserver(observer func(report Report)) Option {
+	return func(e *Executor) {
+		e.observer = observer
+	}
+}
+
+// New 创建一个新的 Executor 实例
+func New(opts ...Option) (*Executor, error) {
+	e := &Executor{
+		maxAttempts: 4,
+		baseDelay:   200 * time.Millisecond,
+		maxDelay:    10 * time.Second,
+		multiplier:  2,
+		classify:    IsRetryable,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.maxAttempts <= 0 {
+		return nil, errors.New("max attempts must be positive")
+	}
+	if e.baseDelay < 0 || e.maxDelay < e.baseDelay || e.multiplier < 1 {
+		return nil, errors.New("invalid backoff")
+	}
+	if e.classify == nil {
+		return nil, errors.New("nil classifier")
+	}
+	return e, nil
+}
+
+// Do 执行 fn，可重试的错误按指数退避加随机抖动重试，熔断器打开时直接返回 ErrCircuitOpen
+// 重试过程中熔断器打开时，返回的错误同时包含 ErrCircuitOpen 和最后一次调用的错误
+func (e *Executor) Do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	attempts := 0
+	err := e.do(ctx, func(ctx context.Context) error {
+		attempts++
+		return fn(ctx)
+	})
+	if e.observer != nil {
+		e.observer(Report{
+			Op:       op,
+			Attempts: attempts,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return err
+}
+
+func (e *Executor) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt < e.maxAttempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(e.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		if e.breaker != nil {
+			if !e.breaker.Allow() {
+				if err != nil {
+					return errors.Join(ErrCircuitOpen, err)
+				}
+				return ErrCircuitOpen
+			}
+		}
+		err = fn(ctx)
+		if err == nil {
+			if e.breaker != nil {
+				e.breaker.Success()
+			}
+			return nil
+		}
+
+		var perm permanent
+		isPermanent := errors.As(err, &perm)
+		if isPermanent {
+			err = perm.err
+		}
+		retryable := e.classify(err)
+		if e.breaker != nil {
+			// 不可重试的错误通常是请求本身有问题或调用方取消，不能说明后端是否可用，
+			// 既不计入失败也不视为恢复，只释放试探名额
+			if retryable {
+				e.breaker.Failure()
+			} else {
+				e.breaker.Release()
+			}
+		}
+		// 调用方的 ctx 已结束时不再重试
+		if !retryable || isPermanent || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// backoff 计算第 attempt 次重试前的等待时间，使用 full jitter
+func (e *Executor) backoff(attempt int) time.Duration {
+	delay := float64(e.baseDelay)
+	for i := 1; i < attempt; i++ {
+		delay *= e.multiplier
+		if delay >= float64(e.maxDelay) {
+			delay = float64(e.maxDelay)
+			break
+		}
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
+
+// IsRetryable 判断错误是否值得重试：服务端 5xx、429、连接被拒绝或重置、超时
+// 以及意外断开的连接是可重试的；ctx 被取消和其他错误是致命的
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if code, ok := statusCode(err); ok {
+		return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// statusCode 从已知的错误类型中取出 HTTP 状态码
+func statusCode(err error) (int, bool) {
+	var ollamaErr api.StatusError
+	if errors.As(err, &ollamaErr) {
+		return ollamaErr.StatusCode, true
+	}
+	var openAIErr *llm.OpenAIError
+	if errors.As(err, &openAIErr) {
+		return openAIErr.StatusCode, true
+	}
+	var httpErr *embedding.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode, true
+	}
+	return 0, false
+}
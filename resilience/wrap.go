@@ -0,0 +1,170 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/llm"
+	"golang.org/x/sync/errgroup"
+)
+
+// resilientEmbedder 为 Embedder 的每次调用加上重试和熔断
+type resilientEmbedder struct {
+	inner    embedding.Embedder
+	executor *Executor
+}
+
+// NewEmbedder 使用 executor 包装 inner，inner 区分查询和文档时同样生效
+// inner 实现 embedding.Batcher 时按批重试，只重新发送失败的批次
+func NewEmbedder(inner embedding.Embedder, executor *Executor) embedding.QueryDocumentEmbedder {
+	return &resilientEmbedder{
+		inner:    inner,
+		executor: executor,
+	}
+}
+
+func (e *resilientEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	var embed []float32
+	err := e.executor.Do(ctx, "Embed", func(ctx context.Context) error {
+		var err error
+		embed, err = e.inner.Embed(ctx, text)
+		return err
+	})
+	return embed, err
+}
+
+func (e *resilientEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embedBatches(ctx, "Embeds", texts, e.inner.Embeds)
+}
+
+func (e *resilientEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	var embed []float32
+	err := e.executor.Do(ctx, "EmbedQuery", func(ctx context.Context) error {
+		var err error
+		embed, err = embedding.EmbedQuery(ctx, e.inner, text)
+		return err
+	})
+	return embed, err
+}
+
+func (e *resilientEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embedBatches(ctx, "EmbedDocuments", texts, func(ctx context.Context, texts []string) ([][]float32, error) {
+		return embedding.EmbedDocuments(ctx, e.inner, texts)
+	})
+}
+
+// embedBatches 按 inner 的批大小拆分 texts，每批单独重试，某一批失败时不会重新发送其他批次
+// 各批次并发调用 inner，并发数量由 inner 自身限制；inner 不分批时整体作为一批
+func (e *resilientEmbedder) embedBatches(ctx context.Context, op string, texts []string, embed func(ctx context.Context, texts []string) ([][]float32, error)) ([][]float32, error) {
+	size := embedding.BatchSize(e.inner)
+	if size <= 0 || len(texts) <= size {
+		var embeds [][]float32
+		err := e.executor.Do(ctx, op, func(ctx context.Context) error {
+			var err error
+			embeds, err = embed(ctx, texts)
+			return err
+		})
+		return embeds, err
+	}
+
+	embeds := make([][]float32, len(texts))
+	g, ctx := errgroup.WithContext(ctx)
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		g.Go(func() error {
+			return e.executor.Do(ctx, op, func(ctx context.Context) error {
+				batch, err := embed(ctx, texts[start:end])
+				if err != nil {
+					return err
+				}
+				if len(batch) != end-start {
+					return permanent{fmt.Errorf("expected %d embeddings, got %d", end-start, len(batch))}
+				}
+				copy(embeds[start:end], batch)
+				return nil
+			})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return embeds, nil
+}
+
+func (e *resilientEmbedder) Normalized() bool {
+	return embedding.IsNormalized(e.inner)
+}
+
+func (e *resilientEmbedder) Model() string {
+	return embedding.ModelName(e.inner)
+}
+
+func (e *resilientEmbedder) BatchSize() int {
+	return embedding.BatchSize(e.inner)
+}
+
+// resilientLLM 为 LLM 的每次调用加上重试和熔断
+type resilientLLM struct {
+	inner    llm.LLM
+	executor *Executor
+}
+
+// NewLLM 使用 executor 包装 inner
+func NewLLM(inner llm.LLM, executor *Executor) llm.LLM {
+	return &resilientLLM{
+		inner:    inner,
+		executor: executor,
+	}
+}
+
+func (l *resilientLLM) ChatCompletion(ctx context.Context, opts llm.ChatCompletionOptions) (*llm.ChatCompletionResponse, error) {
+	var resp *llm.ChatCompletionResponse
+	err := l.executor.Do(ctx, "ChatCompletion", func(ctx context.Context) error {
+		var err error
+		resp, err = l.inner.ChatCompletion(ctx, opts)
+		return err
+	})
+	return resp, err
+}
+
+// ChatCompletionStream 只在尚未输出任何分片时重试，避免调用方收到重复内容
+func (l *resilientLLM) ChatCompletionStream(ctx context.Context, opts llm.ChatCompletionOptions, fn llm.ChatCompletionStreamFunc) error {
+	delivered := false
+	return l.executor.Do(ctx, "ChatCompletionStream", func(ctx context.Context) error {
+		err := l.inner.ChatCompletionStream(ctx, opts, func(chunk *llm.ChatCompletionChunk) error {
+			delivered = true
+			return fn(chunk)
+		})
+		if err != nil && delivered {
+			return permanent{err}
+		}
+		return err
+	})
+}
+
+func (l *resilientLLM) IgnoredOptions(opts llm.ChatCompletionOptions) []string {
+	return l.inner.IgnoredOptions(opts)
+}
+
+func (l *resilientLLM) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	var embed []float32
+	err := l.executor.Do(ctx, "CreateEmbedding", func(ctx context.Context) error {
+		var err error
+		embed, err = l.inner.CreateEmbedding(ctx, text)
+		return err
+	})
+	return embed, err
+}
+
+// permanent 标记不应重试的错误
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+func (p permanent) Unwrap() error {
+	return p.err
+}
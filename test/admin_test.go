@@ -0,0 +1,115 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/vectorstore"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCollectionAdmin(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1}},
+		{Id: "2", Text: "斩黄巾英雄首立功", Metadata: map[string]any{"chapter": 1}},
+		{Id: "3", Text: "张翼德怒鞭督邮", Metadata: map[string]any{"chapter": 2}},
+	}
+	for _, name := range []string{"sgyy", "szbf", "tmp"} {
+		if err := store.AddDocuments(ctx, name, docs); err != nil {
+			t.Fatalf("向量化存储失败: %v", err)
+		}
+	}
+
+	var admin vectorstore.CollectionAdmin = store
+	info, err := admin.CollectionInfo(ctx, "sgyy")
+	if err != nil {
+		t.Fatalf("CollectionInfo 失败: %v", err)
+	}
+	want := vectorstore.CollectionInfo{Name: "sgyy", Count: 3, Dimension: 256, Distance: vectorstore.DistanceCosine, Model: "hash-256-1-3", Normalized: true}
+	if !reflect.DeepEqual(*info, want) {
+		t.Fatalf("expected %+v, got %+v", want, *info)
+	}
+	if n, err := admin.Count(ctx, "sgyy", vectorstore.Eq("chapter", 1)); err != nil || n != 2 {
+		t.Fatalf("expected 2 documents, got %d, %v", n, err)
+	}
+
+	if err := admin.DropCollection(ctx, "tmp"); err != nil {
+		t.Fatalf("DropCollection 失败: %v", err)
+	}
+	if err := admin.RenameCollection(ctx, "szbf", "sgyy"); !errors.Is(err, vectorstore.ErrCollectionExists) {
+		t.Fatalf("expected ErrCollectionExists, got %v", err)
+	}
+	if err := admin.RenameCollection(ctx, "szbf", "sgyy_copy"); err != nil {
+		t.Fatalf("RenameCollection 失败: %v", err)
+	}
+	if err := admin.CreateAlias(ctx, "alias", "sgyy"); !errors.Is(err, vectorstore.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := admin.DropCollection(ctx, "tmp"); !errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+	store.Close()
+
+	// 删除和重命名同样写入追加日志
+	reopened, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer reopened.Close()
+	names, _ := reopened.ListCollections(ctx)
+	if !slices.Equal(names, []string{"sgyy", "sgyy_copy"}) {
+		t.Fatalf("unexpected collections: %v", names)
+	}
+	if ok, _ := reopened.CollectionExists(ctx, "szbf"); ok {
+		t.Fatal("renamed collection still exists")
+	}
+}
+
+func TestQdrantStoreCollectionAdmin(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("admin_%d", time.Now().UnixNano())
+	store := newQdrantStore(t)
+	docs := []*vectorstore.Document{
+		{Id: "6f1c2a3e-0000-4000-8000-000000000003", Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1}},
+		{Id: "6f1c2a3e-0000-4000-8000-000000000004", Text: "张翼德怒鞭督邮", Metadata: map[string]any{"chapter": 2}},
+	}
+	if err := store.AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	admin := store.(vectorstore.CollectionAdmin)
+	if err := admin.CreateAlias(ctx, name+"_alias", name); err != nil {
+		t.Fatalf("CreateAlias 失败: %v", err)
+	}
+	info, err := admin.CollectionInfo(ctx, name)
+	if err != nil {
+		t.Fatalf("CollectionInfo 失败: %v", err)
+	}
+	if info.Dimension != 256 || info.Distance != vectorstore.DistanceDot || info.Model != "hash-256-1-3" || !info.Normalized || !slices.Equal(info.Aliases, []string{name + "_alias"}) {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+	if n, err := admin.Count(ctx, name+"_alias", vectorstore.Eq("chapter", 2)); err != nil || n != 1 {
+		t.Fatalf("expected 1 document, got %d, %v", n, err)
+	}
+	if err := admin.RenameCollection(ctx, name, name+"_new"); !errors.Is(err, vectorstore.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := admin.DeleteAlias(ctx, name+"_alias"); err != nil {
+		t.Fatalf("DeleteAlias 失败: %v", err)
+	}
+	if err := admin.DropCollection(ctx, name); err != nil {
+		t.Fatalf("DropCollection 失败: %v", err)
+	}
+	if ok, _ := admin.CollectionExists(ctx, name); ok {
+		t.Fatal("dropped collection still exists")
+	}
+}
@@ -0,0 +1,226 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/hl540/rag/agent"
+	"github.com/hl540/rag/llm"
+	"github.com/hl540/rag/vectorstore"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedLLM 依次返回预先设定的回复，并记录每次收到的请求
+type scriptedLLM struct {
+	replies  []llm.Message
+	requests []llm.ChatCompletionOptions
+}
+
+func (s *scriptedLLM) ChatCompletion(ctx context.Context, opts llm.ChatCompletionOptions) (*llm.ChatCompletionResponse, error) {
+	s.requests = append(s.requests, opts)
+	if len(s.replies) == 0 {
+		return nil, errors.New("no more replies")
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	resp := &llm.ChatCompletionResponse{Message: reply, FinishReason: "stop"}
+	if len(reply.ToolCalls) > 0 {
+		resp.FinishReason = llm.FinishReasonToolCalls
+	}
+	return resp, nil
+}
+
+func (s *scriptedLLM) ChatCompletionStream(ctx context.Context, opts llm.ChatCompletionOptions, fn llm.ChatCompletionStreamFunc) error {
+	resp, err := s.ChatCompletion(ctx, opts)
+	if err != nil {
+		return err
+	}
+	return fn(&llm.ChatCompletionChunk{Delta: resp.Message.Content, Done: true, FinishReason: resp.FinishReason, Message: &resp.Message})
+}
+
+func (s *scriptedLLM) IgnoredOptions(opts llm.ChatCompletionOptions) []string {
+	return nil
+}
+
+func (s *scriptedLLM) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, errors.New("not supported")
+}
+
+func toolCallMessage(name string, args string) llm.Message {
+	return llm.Message{
+		Role:      llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "call_0", Name: name, Arguments: json.RawMessage(args)}},
+	}
+}
+
+func TestAgentRun(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("滚滚长江东逝水"))
+	}))
+	defer page.Close()
+
+	dir := t.TempDir()
+	createTool, err := agent.NewFileCreateTool(dir)
+	if err != nil {
+		t.Fatalf("创建文件工具失败: %v", err)
+	}
+	registry, err := agent.NewRegistry(agent.NewHTTPTool(page.Client(), 0), createTool)
+	if err != nil {
+		t.Fatalf("注册工具失败: %v", err)
+	}
+
+	model := &scriptedLLM{replies: []llm.Message{
+		toolCallMessage("http_request", `{"url":"`+page.URL+`"}`),
+		toolCallMessage("create_file", `{"name":"poem.txt","content":"滚滚长江东逝水"}`),
+		{Role: llm.RoleAssistant, Content: "已保存"},
+	}}
+	a, err := agent.New(model, registry)
+	if err != nil {
+		t.Fatalf("创建 agent 失败: %v", err)
+	}
+	result, err := a.Run(context.Background(), []llm.Message{{Role: llm.RoleUser, Content: "抓取网页并保存"}})
+	if err != nil {
+		t.Fatalf("agent 运行失败: %v", err)
+	}
+	if result.Answer != "已保存" || result.Steps != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(model.requests[0].Tools) != 2 {
+		t.Fatalf("unexpected tools: %+v", model.requests[0].Tools)
+	}
+	fetched := model.requests[1].Messages[len(model.requests[1].Messages)-1]
+	if fetched.Role != llm.RoleTool || fetched.Content != "滚滚长江东逝水" {
+		t.Fatalf("unexpected tool result: %+v", fetched)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "poem.txt"))
+	if err != nil || string(content) != "滚滚长江东逝水" {
+		t.Fatalf("unexpected file content: %q, %v", content, err)
+	}
+}
+
+func TestAgentSandboxAndConfirm(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	createTool, err := agent.NewFileCreateTool(dir)
+	if err != nil {
+		t.Fatalf("创建文件工具失败: %v", err)
+	}
+	deleteTool, err := agent.NewFileDeleteTool(dir)
+	if err != nil {
+		t.Fatalf("删除文件工具失败: %v", err)
+	}
+	registry, err := agent.NewRegistry(createTool, deleteTool)
+	if err != nil {
+		t.Fatalf("注册工具失败: %v", err)
+	}
+
+	// 未设置确认函数时，破坏性工具被拒绝执行；越出根目录的路径无法创建
+	model := &scriptedLLM{replies: []llm.Message{
+		toolCallMessage("create_file", `{"name":"../escape.txt"}`),
+		toolCallMessage("delete_files", `{"paths":["a.txt"]}`),
+		{Role: llm.RoleAssistant, Content: "done"},
+	}}
+	a, err := agent.New(model, registry)
+	if err != nil {
+		t.Fatalf("创建 agent 失败: %v", err)
+	}
+	result, err := a.Run(context.Background(), []llm.Message{{Role: llm.RoleUser, Content: "go"}})
+	if err != nil {
+		t.Fatalf("agent 运行失败: %v", err)
+	}
+	if msg := result.Messages[2]; !strings.HasPrefix(msg.Content, "error:") {
+		t.Fatalf("expected sandbox error, got %q", msg.Content)
+	}
+	if msg := result.Messages[4]; !strings.Contains(msg.Content, "requires confirmation") {
+		t.Fatalf("expected confirmation error, got %q", msg.Content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); err != nil {
+		t.Fatalf("file should not be deleted: %v", err)
+	}
+
+	// 确认后才会删除
+	model = &scriptedLLM{replies: []llm.Message{
+		toolCallMessage("delete_files", `{"paths":["a.txt"]}`),
+		{Role: llm.RoleAssistant, Content: "done"},
+	}}
+	a, err = agent.New(model, registry, agent.WithConfirm(func(ctx context.Context, call llm.ToolCall) (bool, error) {
+		return call.Name == "delete_files", nil
+	}))
+	if err != nil {
+		t.Fatalf("创建 agent 失败: %v", err)
+	}
+	if _, err := a.Run(context.Background(), []llm.Message{{Role: llm.RoleUser, Content: "go"}}); err != nil {
+		t.Fatalf("agent 运行失败: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file should be deleted: %v", err)
+	}
+}
+
+func TestAgentMaxSteps(t *testing.T) {
+	registry, err := agent.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model := &scriptedLLM{replies: []llm.Message{
+		toolCallMessage("unknown", `{}`),
+		toolCallMessage("unknown", `{}`),
+	}}
+	a, err := agent.New(model, registry, agent.WithMaxSteps(2))
+	if err != nil {
+		t.Fatalf("创建 agent 失败: %v", err)
+	}
+	result, err := a.Run(context.Background(), []llm.Message{{Role: llm.RoleUser, Content: "go"}})
+	if !errors.Is(err, agent.ErrMaxSteps) || result.Steps != 2 {
+		t.Fatalf("expected ErrMaxSteps after 2 steps, got %v, %+v", err, result)
+	}
+}
+
+func TestAgentRetrievalTool(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "孙子曰：兵者，国之大事"},
+		{Id: "2", Text: "滚滚长江东逝水"},
+	}
+	if err := store.AddDocuments(ctx, "szbf", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	tool, err := agent.NewRetrievalTool(store, "szbf")
+	if err != nil {
+		t.Fatalf("创建检索工具失败: %v", err)
+	}
+	registry, err := agent.NewRegistry(tool)
+	if err != nil {
+		t.Fatalf("注册工具失败: %v", err)
+	}
+
+	// 一轮对话中先后检索两次，第二次检索的集合不在白名单中
+	model := &scriptedLLM{replies: []llm.Message{
+		toolCallMessage("vector_search", `{"collection":"szbf","query":"兵者","top_k":1}`),
+		toolCallMessage("vector_search", `{"collection":"sgyy","query":"长江"}`),
+		{Role: llm.RoleAssistant, Content: "兵者，国之大事"},
+	}}
+	a, err := agent.New(model, registry)
+	if err != nil {
+		t.Fatalf("创建 agent 失败: %v", err)
+	}
+	result, err := a.Run(ctx, []llm.Message{{Role: llm.RoleUser, Content: "孙子兵法第一句是什么"}})
+	if err != nil {
+		t.Fatalf("agent 运行失败: %v", err)
+	}
+	first := result.Messages[2].Content
+	if !strings.HasPrefix(first, "[1] id=1 ") || !strings.Contains(first, "国之大事") || strings.Contains(first, "[2]") {
+		t.Fatalf("unexpected search result: %q", first)
+	}
+	if second := result.Messages[4].Content; !strings.Contains(second, "not allowed") {
+		t.Fatalf("expected collection error, got %q", second)
+	}
+}
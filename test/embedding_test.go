@@ -0,0 +1,369 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/vectorstore"
+	"github.com/ollama/ollama/api"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOllamaEmbedServer 启动一个模拟 Ollama /api/embed 的测试服务，向量第一维为文本的序号
+func newOllamaEmbedServer(t *testing.T, requests *atomic.Int64) *api.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req struct {
+			Input any `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var inputs []string
+		switch input := req.Input.(type) {
+		case string:
+			inputs = []string{input}
+		case []any:
+			for _, item := range input {
+				inputs = append(inputs, item.(string))
+			}
+		}
+		embeds := make([][]float32, 0, len(inputs))
+		for _, input := range inputs {
+			var n float32
+			_, _ = fmt.Sscanf(input, "text-%f", &n)
+			embeds = append(embeds, []float32{n, 1, 0})
+		}
+		_ = json.NewEncoder(w).Encode(api.EmbedResponse{Embeddings: embeds})
+	}))
+	t.Cleanup(server.Close)
+	base, _ := url.Parse(server.URL)
+	return api.NewClient(base, server.Client())
+}
+
+func TestOllamaEmbedderBatch(t *testing.T) {
+	var requests atomic.Int64
+	client := newOllamaEmbedServer(t, &requests)
+	embedder := embedding.NewOllamaEmbedder(client, "bge-m3", embedding.WithBatchSize(16), embedding.WithConcurrency(3))
+
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text-%d", i)
+	}
+	embeds, err := embedder.Embeds(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	for i, embed := range embeds {
+		if embed[0] != float32(i) {
+			t.Fatalf("embedding %d out of order: %v", i, embed)
+		}
+	}
+	if n := requests.Load(); n != 7 {
+		t.Fatalf("expected 7 requests for 100 texts in batches of 16, got %d", n)
+	}
+}
+
+func TestOllamaEmbedderOptions(t *testing.T) {
+	var got api.EmbedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(api.EmbedResponse{Embeddings: [][]float32{{1, 2, 3}}})
+	}))
+	defer server.Close()
+	base, _ := url.Parse(server.URL)
+	client := api.NewClient(base, server.Client())
+
+	embedder := embedding.NewOllamaEmbedder(client, "bge-m3",
+		embedding.WithTruncate(false),
+		embedding.WithKeepAlive(10*time.Minute),
+		embedding.WithModelOptions(map[string]any{"num_ctx": 512}),
+		embedding.WithTimeout(time.Second),
+		embedding.WithDimension(3),
+	)
+	if _, err := embedder.Embed(context.Background(), "滚滚长江东逝水"); err != nil {
+		t.Fatalf("Embed 失败: %v", err)
+	}
+	if got.Truncate == nil || *got.Truncate || got.KeepAlive == nil || got.KeepAlive.Duration != 10*time.Minute {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if got.Options["num_ctx"] != float64(512) {
+		t.Fatalf("unexpected options: %+v", got.Options)
+	}
+
+	embedder = embedding.NewOllamaEmbedder(client, "bge-m3", embedding.WithDimension(768))
+	if _, err := embedder.Embed(context.Background(), "滚滚长江东逝水"); !errors.Is(err, embedding.ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestOllamaEmbedderBatchError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"model is loading"}`))
+	}))
+	defer server.Close()
+	base, _ := url.Parse(server.URL)
+	client := api.NewClient(base, server.Client())
+	embedder := embedding.NewOllamaEmbedder(client, "bge-m3", embedding.WithBatchSize(1), embedding.WithConcurrency(2))
+
+	texts := make([]string, 50)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text-%d", i)
+	}
+	// 一个批次失败后后续批次不再启动，返回的仍然是批次的原始错误而不是 context canceled
+	for i := 0; i < 20; i++ {
+		_, err := embedder.Embeds(context.Background(), texts)
+		var statusErr api.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503 StatusError, got %v", err)
+		}
+	}
+}
+
+// countingEmbedder 统计传给 inner 的文本数量
+type countingEmbedder struct {
+	inner embedding.Embedder
+	texts atomic.Int64
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.texts.Add(1)
+	return e.inner.Embed(ctx, text)
+}
+
+func (e *countingEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts.Add(int64(len(texts)))
+	return e.inner.Embeds(ctx, texts)
+}
+
+func TestCachedEmbedder(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &countingEmbedder{inner: newHashEmbedder(t)}
+	cache, err := embedding.NewCachedEmbedder(inner, "hash", dir)
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+
+	texts := []string{"兵者", "国之大事", "兵者", "死生之地"}
+	first, err := cache.Embeds(ctx, texts)
+	if err != nil {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	if n := inner.texts.Load(); n != 3 {
+		t.Fatalf("expected 3 unique texts forwarded, got %d", n)
+	}
+	if _, err := cache.Embeds(ctx, append(texts, "存亡之道")); err != nil {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	if n := inner.texts.Load(); n != 4 {
+		t.Fatalf("expected only the miss to be forwarded, got %d", n)
+	}
+	if stats := cache.Stats(); stats.Hits != 4 || stats.Entries != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// 重新打开缓存目录，不再调用 inner
+	reopened, err := embedding.NewCachedEmbedder(inner, "hash", dir)
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+	embed, err := reopened.Embed(ctx, "国之大事")
+	if err != nil {
+		t.Fatalf("Embed 失败: %v", err)
+	}
+	if inner.texts.Load() != 4 || !reflect.DeepEqual(embed, first[1]) {
+		t.Fatalf("expected cache hit from disk")
+	}
+
+	// 模型名称不同时不会命中
+	other, err := embedding.NewCachedEmbedder(inner, "other", dir)
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+	if _, err := other.Embed(ctx, "国之大事"); err != nil || inner.texts.Load() != 5 {
+		t.Fatalf("expected miss for another model: %v", err)
+	}
+}
+
+func TestCachedEmbedderEviction(t *testing.T) {
+	ctx := context.Background()
+	cache, err := embedding.NewCachedEmbedder(newHashEmbedder(t), "hash", t.TempDir(), embedding.WithCacheMaxEntries(2))
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+	for _, text := range []string{"一", "二", "一", "三"} {
+		if _, err := cache.Embed(ctx, text); err != nil {
+			t.Fatalf("Embed 失败: %v", err)
+		}
+	}
+	// "二" 最久未使用，应被淘汰
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if _, err := cache.Embed(ctx, "一"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Stats().Hits != stats.Hits+1 {
+		t.Fatal("expected recently used entry to survive eviction")
+	}
+
+	// 重启后仍按使用顺序淘汰：先写入但最近命中的 "一" 保留
+	dir := t.TempDir()
+	cache, err = embedding.NewCachedEmbedder(newHashEmbedder(t), "hash", dir)
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+	for _, text := range []string{"一", "二", "一"} {
+		if _, err := cache.Embed(ctx, text); err != nil {
+			t.Fatalf("Embed 失败: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	reopened, err := embedding.NewCachedEmbedder(newHashEmbedder(t), "hash", dir, embedding.WithCacheMaxEntries(1))
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+	if _, err := reopened.Embed(ctx, "一"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := reopened.Stats(); stats.Hits != 1 || stats.Evictions != 1 {
+		t.Fatalf("expected recently used entry to survive restart: %+v", stats)
+	}
+}
+
+func TestCachedEmbedderQueryDocument(t *testing.T) {
+	ctx := context.Background()
+	recorder := &recordingEmbedder{Embedder: newHashEmbedder(t)}
+	prefixed, err := embedding.NewPrefixedEmbedder(recorder, embedding.PrefixTemplate{Query: "query: ", Document: "passage: "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := embedding.NewCachedEmbedder(prefixed, "hash", t.TempDir())
+	if err != nil {
+		t.Fatalf("缓存创建失败: %v", err)
+	}
+
+	// 同一文本在查询、文档和普通路径下各自计算，前缀不会丢失
+	if _, err := embedding.EmbedDocuments(ctx, cache, []string{"兵者"}); err != nil {
+		t.Fatalf("EmbedDocuments 失败: %v", err)
+	}
+	if _, err := embedding.EmbedQuery(ctx, cache, "兵者"); err != nil {
+		t.Fatalf("EmbedQuery 失败: %v", err)
+	}
+	if _, err := cache.Embed(ctx, "兵者"); err != nil {
+		t.Fatalf("Embed 失败: %v", err)
+	}
+	want := []string{"passage: 兵者", "query: 兵者", "兵者"}
+	if !reflect.DeepEqual(recorder.texts, want) {
+		t.Fatalf("expected %q, got %q", want, recorder.texts)
+	}
+
+	// 再次请求全部命中缓存
+	if _, err := embedding.EmbedQuery(ctx, cache, "兵者"); err != nil {
+		t.Fatalf("EmbedQuery 失败: %v", err)
+	}
+	if _, err := embedding.EmbedDocuments(ctx, cache, []string{"兵者"}); err != nil {
+		t.Fatalf("EmbedDocuments 失败: %v", err)
+	}
+	if len(recorder.texts) != 3 {
+		t.Fatalf("expected cache hits, got %q", recorder.texts)
+	}
+}
+
+// recordingEmbedder 记录收到的文本
+type recordingEmbedder struct {
+	embedding.Embedder
+	texts []string
+}
+
+func (e *recordingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	return e.Embedder.Embed(ctx, text)
+}
+
+func (e *recordingEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	e.texts = append(e.texts, texts...)
+	return e.Embedder.Embeds(ctx, texts)
+}
+
+func TestPrefixedEmbedder(t *testing.T) {
+	ctx := context.Background()
+	template, ok := embedding.PrefixTemplateFor("quentinz/bge-base-zh-v1.5:latest")
+	if !ok {
+		t.Fatal("expected template for bge-base-zh")
+	}
+	inner := &recordingEmbedder{Embedder: newHashEmbedder(t)}
+	embedder, err := embedding.NewPrefixedEmbedder(inner, template)
+	if err != nil {
+		t.Fatalf("embedder 创建失败: %v", err)
+	}
+
+	store := vectorstore.NewMemoryStore(embedder)
+	docs := []*vectorstore.Document{{Id: "1", Text: "滚滚长江东逝水"}}
+	if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	if _, err := store.SimilaritySearch(ctx, "sgyy", "长江", 1); err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	want := []string{"滚滚长江东逝水", "为这个句子生成表示以用于检索相关文章：长江"}
+	if !reflect.DeepEqual(inner.texts, want) {
+		t.Fatalf("unexpected texts: %q", inner.texts)
+	}
+
+	inner.texts = nil
+	embedder, _ = embedding.NewPrefixedEmbedder(inner, embedding.PrefixTemplate{Query: "<q>{text}</q>", Document: "doc: "})
+	if _, err := embedder.EmbedDocuments(ctx, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := embedder.EmbedQuery(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(inner.texts, []string{"doc: a", "<q>b</q>"}) {
+		t.Fatalf("unexpected texts: %q", inner.texts)
+	}
+}
+
+func TestNormalizedEmbedder(t *testing.T) {
+	ctx := context.Background()
+	var requests atomic.Int64
+	client := newOllamaEmbedServer(t, &requests)
+	inner := embedding.NewOllamaEmbedder(client, "nomic-embed-text")
+	if embedding.IsNormalized(inner) {
+		t.Fatal("ollama embedder should not report normalized vectors")
+	}
+
+	// 模拟服务返回 [n, 1, 0]，截取前两维后归一化
+	embedder, err := embedding.NewNormalizedEmbedder(inner, 2)
+	if err != nil {
+		t.Fatalf("embedder 创建失败: %v", err)
+	}
+	embed, err := embedder.Embed(ctx, "text-3")
+	if err != nil {
+		t.Fatalf("Embed 失败: %v", err)
+	}
+	if len(embed) != 2 || math.Abs(float64(embed[0])-0.9486833) > 1e-6 || math.Abs(float64(embed[1])-0.31622776) > 1e-6 {
+		t.Fatalf("unexpected embedding: %v", embed)
+	}
+	if !embedding.IsNormalized(embedder) {
+		t.Fatal("expected normalized embedder")
+	}
+
+	embedder, _ = embedding.NewNormalizedEmbedder(inner, 8)
+	if _, err := embedder.Embed(ctx, "text-3"); !errors.Is(err, embedding.ErrDimensionMismatch) {
+		t.Fatalf("expected ErrDimensionMismatch, got %v", err)
+	}
+}
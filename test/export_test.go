@@ -0,0 +1,157 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/vectorstore"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestMemoryStoreScroll(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t)).(*vectorstore.MemoryStore)
+	docs := make([]*vectorstore.Document, 0, 25)
+	for i := 0; i < 25; i++ {
+		docs = append(docs, &vectorstore.Document{Id: fmt.Sprintf("%02d", i), Text: fmt.Sprintf("第%d回", i), Metadata: map[string]any{"odd": i%2 == 1}})
+	}
+	if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	// 删除会打乱内部顺序，翻页仍然按 ID 排序
+	if err := store.DeleteDocuments(ctx, "sgyy", []string{"03"}); err != nil {
+		t.Fatalf("DeleteDocuments 失败: %v", err)
+	}
+
+	page, err := store.ScrollDocuments(ctx, "sgyy", vectorstore.ScrollOptions{Limit: 10, Filter: vectorstore.Eq("odd", true)})
+	if err != nil {
+		t.Fatalf("ScrollDocuments 失败: %v", err)
+	}
+	if len(page.Documents) != 10 || page.Documents[0].Id != "01" || page.Documents[1].Id != "05" || page.NextOffset != "23" {
+		t.Fatalf("unexpected page: %d documents, first %s, next %s", len(page.Documents), page.Documents[0].Id, page.NextOffset)
+	}
+	if page.Documents[0].Embedding != nil {
+		t.Fatal("embedding returned without WithEmbeddings")
+	}
+
+	var ids []string
+	err = vectorstore.ScrollAll(ctx, store, "sgyy", vectorstore.ScrollOptions{Limit: 7, WithEmbeddings: true}, func(doc *vectorstore.Document) error {
+		if len(doc.Embedding) != 256 {
+			return fmt.Errorf("document %s has %d dimensions", doc.Id, len(doc.Embedding))
+		}
+		ids = append(ids, doc.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScrollAll 失败: %v", err)
+	}
+	if len(ids) != 24 || ids[2] != "02" || ids[3] != "04" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if _, err := store.ScrollDocuments(ctx, "missing", vectorstore.ScrollOptions{}); !errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1, "tags": []any{"刘备", "关羽"}}},
+		{Id: "2", Text: "斩黄巾英雄首立功", Metadata: map[string]any{"chapter": 1, "score": 0.5}},
+		{Id: "3", Text: "张翼德怒鞭督邮"},
+	}
+	if err := source.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := vectorstore.Export(ctx, source, "sgyy", &buf); err != nil {
+		t.Fatalf("Export 失败: %v", err)
+	}
+	data := buf.Bytes()
+
+	// 导入时不调用嵌入器
+	counter := &countingEmbedder{inner: newHashEmbedder(t)}
+	embedder, err := embedding.NewNormalizedEmbedder(counter, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := vectorstore.NewMemoryStore(embedder)
+	n, err := vectorstore.Import(ctx, target, "restored", bytes.NewReader(data))
+	if err != nil || n != 3 {
+		t.Fatalf("Import 失败: %d, %v", n, err)
+	}
+	if c := counter.texts.Load(); c != 0 {
+		t.Fatalf("expected no texts embedded during import, got %d", c)
+	}
+	got, _ := target.GetDocuments(ctx, "restored", []string{"1", "2", "3"})
+	want := map[string]any{"chapter": int64(1), "tags": []any{"刘备", "关羽"}}
+	if len(got) != 3 || got[0].Text != docs[0].Text || !reflect.DeepEqual(got[0].Metadata, want) || got[1].Metadata["score"] != 0.5 || got[2].Metadata != nil {
+		t.Fatalf("unexpected documents: %+v", got)
+	}
+	expected, _ := source.SimilaritySearch(ctx, "sgyy", "桃园", 3)
+	results, err := target.SimilaritySearch(ctx, "restored", "桃园", 3)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	for i := range expected {
+		if results[i].ID != expected[i].ID || results[i].Score != expected[i].Score {
+			t.Fatalf("result %d: expected %s(%f), got %s(%f)", i, expected[i].ID, expected[i].Score, results[i].ID, results[i].Score)
+		}
+	}
+
+	// 目标向量存储的模型不同时拒绝导入，新建的集合被删除
+	other, err := embedding.NewHashEmbedder(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := vectorstore.NewMemoryStore(other)
+	if _, err := vectorstore.Import(ctx, mismatched, "restored", bytes.NewReader(data)); !errors.Is(err, vectorstore.ErrModelMismatch) {
+		t.Fatalf("expected ErrModelMismatch, got %v", err)
+	}
+	if ok, _ := mismatched.(vectorstore.CollectionAdmin).CollectionExists(ctx, "restored"); ok {
+		t.Fatal("collection should be dropped after failed import")
+	}
+}
+
+func TestMemoryStoreInvalidEmbedding(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	if err := store.AddDocuments(ctx, "sgyy", []*vectorstore.Document{{Id: "1", Text: "宴桃园豪杰三结义"}}); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	valid := make([]float32, 256)
+	valid[0] = 1
+	cases := []struct {
+		name string
+		docs []*vectorstore.Document
+		want error
+	}{
+		{"nan", []*vectorstore.Document{{Id: "2", Embedding: append([]float32{float32(math.NaN())}, valid[1:]...)}}, vectorstore.ErrInvalidEmbedding},
+		{"inf", []*vectorstore.Document{{Id: "2", Embedding: append([]float32{float32(math.Inf(1))}, valid[1:]...)}}, vectorstore.ErrInvalidEmbedding},
+		{"zero", []*vectorstore.Document{{Id: "2", Embedding: make([]float32, 256)}}, vectorstore.ErrInvalidEmbedding},
+		{"collection dimension", []*vectorstore.Document{{Id: "2", Embedding: []float32{1, 0}}}, vectorstore.ErrSchemaMismatch},
+		{"batch dimension", []*vectorstore.Document{{Id: "2", Text: "斩黄巾英雄首立功"}, {Id: "3", Embedding: []float32{1, 0}}}, vectorstore.ErrSchemaMismatch},
+	}
+	for _, tc := range cases {
+		if err := store.AddDocuments(ctx, "sgyy", tc.docs); !errors.Is(err, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, err)
+		}
+	}
+	// 新集合中同一批次的维度也必须一致
+	if err := store.AddDocuments(ctx, "new", []*vectorstore.Document{{Id: "1", Embedding: []float32{1, 0}}, {Id: "2", Embedding: []float32{1, 0, 0}}}); !errors.Is(err, vectorstore.ErrSchemaMismatch) {
+		t.Fatalf("expected ErrSchemaMismatch, got %v", err)
+	}
+
+	// 被拒绝的向量没有写入，集合仍然可以检索
+	if err := store.AddDocuments(ctx, "sgyy", []*vectorstore.Document{{Id: "2", Text: "一", Embedding: valid}}); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	if results, err := store.SimilaritySearch(ctx, "sgyy", "桃园", 5, vectorstore.WithExactSearch()); err != nil || len(results) != 2 {
+		t.Fatalf("vector 查询失败: %v, %+v", err, results)
+	}
+}
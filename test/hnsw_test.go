@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hl540/rag/vectorstore"
+	"hash/fnv"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomEmbedder 根据文本的哈希生成确定的随机向量，用于构造合成语料
+type randomEmbedder struct {
+	dimension int
+}
+
+func (e *randomEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	rng := rand.New(rand.NewPCG(h.Sum64(), 0))
+	embed := make([]float32, e.dimension)
+	for i := range embed {
+		embed[i] = float32(rng.NormFloat64())
+	}
+	return embed, nil
+}
+
+func (e *randomEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	embeds := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		embed, _ := e.Embed(ctx, text)
+		embeds = append(embeds, embed)
+	}
+	return embeds, nil
+}
+
+// newSyntheticStore 创建一个写入 n 条合成文档的存储
+func newSyntheticStore(tb testing.TB, n int, opts ...vectorstore.MemoryOption) vectorstore.VectorStore {
+	tb.Helper()
+	store := vectorstore.NewMemoryStore(&randomEmbedder{dimension: 32}, opts...)
+	docs := make([]*vectorstore.Document, 0, n)
+	for i := 0; i < n; i++ {
+		docs = append(docs, &vectorstore.Document{Id: fmt.Sprintf("doc-%d", i), Text: fmt.Sprintf("doc-%d", i)})
+	}
+	if err := store.AddDocuments(context.Background(), "synthetic", docs); err != nil {
+		tb.Fatalf("向量化存储失败: %v", err)
+	}
+	return store
+}
+
+// recall 计算 store 的近似检索结果相对精确检索的召回率
+func recall(tb testing.TB, store vectorstore.VectorStore, queries, topK int) float64 {
+	tb.Helper()
+	ctx := context.Background()
+	hits, total := 0, 0
+	for q := 0; q < queries; q++ {
+		query := fmt.Sprintf("query-%d", q)
+		exact, err := store.SimilaritySearch(ctx, "synthetic", query, topK, vectorstore.WithExactSearch())
+		if err != nil {
+			tb.Fatalf("vector 查询失败: %v", err)
+		}
+		approximate, err := store.SimilaritySearch(ctx, "synthetic", query, topK)
+		if err != nil {
+			tb.Fatalf("vector 查询失败: %v", err)
+		}
+		want := make(map[string]bool, len(exact))
+		for _, result := range exact {
+			want[result.ID] = true
+		}
+		for _, result := range approximate {
+			if want[result.ID] {
+				hits++
+			}
+		}
+		total += len(exact)
+	}
+	return float64(hits) / float64(total)
+}
+
+func TestMemoryStoreHNSW(t *testing.T) {
+	ctx := context.Background()
+	store := newSyntheticStore(t, 2000, vectorstore.WithHNSW(vectorstore.HNSWConfig{}))
+	if r := recall(t, store, 50, 10); r < 0.9 {
+		t.Fatalf("recall@10 too low: %.3f", r)
+	}
+
+	// 删除一半文档后，被删除的文档不再出现在结果中
+	deleted := make([]string, 0, 1000)
+	for i := 0; i < 2000; i += 2 {
+		deleted = append(deleted, fmt.Sprintf("doc-%d", i))
+	}
+	if err := store.DeleteDocuments(ctx, "synthetic", deleted); err != nil {
+		t.Fatalf("DeleteDocuments 失败: %v", err)
+	}
+	for q := 0; q < 20; q++ {
+		results, err := store.SimilaritySearch(ctx, "synthetic", fmt.Sprintf("query-%d", q), 10)
+		if err != nil {
+			t.Fatalf("vector 查询失败: %v", err)
+		}
+		if len(results) != 10 {
+			t.Fatalf("expected 10 results, got %d", len(results))
+		}
+		for _, result := range results {
+			var i int
+			fmt.Sscanf(result.ID, "doc-%d", &i)
+			if i%2 == 0 {
+				t.Fatalf("deleted document %s returned", result.ID)
+			}
+		}
+	}
+	if r := recall(t, store, 50, 10); r < 0.9 {
+		t.Fatalf("recall@10 after deletes too low: %.3f", r)
+	}
+
+	// 带过滤条件时回退到暴力检索
+	upsert := []*vectorstore.Document{{Id: "doc-1", Text: "query-0", Metadata: map[string]any{"tag": "x"}}}
+	if err := store.AddDocuments(ctx, "synthetic", upsert); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	results, err := store.SimilaritySearch(ctx, "synthetic", "query-0", 5, vectorstore.WithFilter(vectorstore.Eq("tag", "x")))
+	if err != nil || len(results) != 1 || results[0].ID != "doc-1" {
+		t.Fatalf("unexpected results: %+v, %v", results, err)
+	}
+	if results, _ := store.SimilaritySearch(ctx, "synthetic", "query-0", 1); results[0].ID != "doc-1" {
+		t.Fatalf("expected upserted doc-1 first, got %s", results[0].ID)
+	}
+}
+
+func BenchmarkMemoryStoreSearch(b *testing.B) {
+	const corpus, topK = 10000, 10
+	stores := []struct {
+		name  string
+		opts  []vectorstore.MemoryOption
+		exact bool
+	}{
+		{"brute", nil, true},
+		{"hnsw", []vectorstore.MemoryOption{vectorstore.WithHNSW(vectorstore.HNSWConfig{})}, false},
+		{"hnsw-ef128", []vectorstore.MemoryOption{vectorstore.WithHNSW(vectorstore.HNSWConfig{EfSearch: 128})}, false},
+	}
+	for _, s := range stores {
+		b.Run(s.name, func(b *testing.B) {
+			store := newSyntheticStore(b, corpus, s.opts...)
+			var opts []vectorstore.SearchOption
+			if s.exact {
+				opts = append(opts, vectorstore.WithExactSearch())
+			}
+			ctx := context.Background()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.SimilaritySearch(ctx, "synthetic", fmt.Sprintf("query-%d", i%1000), topK, opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if !s.exact {
+				b.StopTimer()
+				b.ReportMetric(recall(b, store, 100, topK), "recall@10")
+			}
+		})
+	}
+}
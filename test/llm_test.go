@@ -0,0 +1,266 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/hl540/rag/llm"
+	"github.com/ollama/ollama/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newOllamaServer 启动一个模拟 Ollama /api/chat 的测试服务
+func newOllamaServer(t *testing.T, handler func(req api.ChatRequest) []api.ChatResponse) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, resp := range handler(req) {
+			_ = enc.Encode(resp)
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaChatCompletion(t *testing.T) {
+	server := newOllamaServer(t, func(req api.ChatRequest) []api.ChatResponse {
+		if len(req.Messages) != 2 || req.Messages[0].Role != llm.RoleSystem {
+			t.Errorf("unexpected messages: %+v", req.Messages)
+		}
+		return []api.ChatResponse{{
+			Model:   req.Model,
+			Message: api.Message{Role: llm.RoleAssistant, Content: "刘备、关羽、张飞"},
+			Done:    true,
+		}}
+	})
+
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	reply, err := client.ChatCompletion(context.Background(), llm.ChatCompletionOptions{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "你是三国演义专家"},
+			{Role: llm.RoleUser, Content: "三英战吕布是那几个人？"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if reply.Message.Content != "刘备、关羽、张飞" {
+		t.Fatalf("unexpected reply: %q", reply.Message.Content)
+	}
+}
+
+func TestOllamaChatCompletionInvalidMessages(t *testing.T) {
+	client, err := llm.New("http://127.0.0.1:0", "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := client.ChatCompletion(ctx, llm.ChatCompletionOptions{}); err == nil {
+		t.Fatal("expected error for empty messages")
+	}
+	_, err = client.ChatCompletion(ctx, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: "robot", Content: "hi"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for unknown role")
+	}
+}
+
+func TestOllamaChatCompletionStream(t *testing.T) {
+	server := newOllamaServer(t, func(req api.ChatRequest) []api.ChatResponse {
+		if req.Stream == nil || !*req.Stream {
+			t.Error("expected stream request")
+		}
+		return []api.ChatResponse{
+			{Message: api.Message{Role: llm.RoleAssistant, Content: "黄盖"}},
+			{Message: api.Message{Role: llm.RoleAssistant, Content: "诈降"}},
+			{Message: api.Message{Role: llm.RoleAssistant}, Done: true, DoneReason: "stop"},
+		}
+	})
+
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	var deltas []string
+	var final *llm.ChatCompletionChunk
+	err = client.ChatCompletionStream(context.Background(), llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "赤壁之战东吴诈降的是那位将军？"}},
+	}, func(chunk *llm.ChatCompletionChunk) error {
+		if chunk.Done {
+			final = chunk
+			return nil
+		}
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream 失败: %v", err)
+	}
+	if len(deltas) != 2 || deltas[0] != "黄盖" || deltas[1] != "诈降" {
+		t.Fatalf("unexpected deltas: %q", deltas)
+	}
+	if final == nil || final.FinishReason != "stop" || final.Message.Content != "黄盖诈降" {
+		t.Fatalf("unexpected final chunk: %+v", final)
+	}
+}
+
+func TestOllamaChatCompletionStreamCancel(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		enc := json.NewEncoder(w)
+		_ = enc.Encode(api.ChatResponse{Message: api.Message{Role: llm.RoleAssistant, Content: "白衣"}})
+		w.(http.Flusher).Flush()
+		// 一直阻塞到客户端断开连接
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = client.ChatCompletionStream(ctx, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "解释一下白衣渡江"}},
+	}, func(chunk *llm.ChatCompletionChunk) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	<-done
+}
+
+func TestOllamaChatCompletionOptions(t *testing.T) {
+	server := newOllamaServer(t, func(req api.ChatRequest) []api.ChatResponse {
+		if req.Model != "qwen3:8b" {
+			t.Errorf("unexpected model: %s", req.Model)
+		}
+		if req.KeepAlive == nil || req.KeepAlive.Duration != 5*time.Minute {
+			t.Errorf("unexpected keep alive: %v", req.KeepAlive)
+		}
+		want := map[string]any{
+			"temperature":    0.2,
+			"top_p":          0.9,
+			"top_k":          float64(40),
+			"num_predict":    float64(128),
+			"stop":           []any{"\n\n"},
+			"seed":           float64(42),
+			"repeat_penalty": 1.1,
+		}
+		if !reflect.DeepEqual(req.Options, want) {
+			t.Errorf("unexpected options: %+v", req.Options)
+		}
+		return []api.ChatResponse{{Message: api.Message{Role: llm.RoleAssistant, Content: "ok"}, Done: true}}
+	})
+
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	opts := llm.ChatCompletionOptions{
+		Messages:      []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+		Model:         "qwen3:8b",
+		Temperature:   llm.Ptr(0.2),
+		TopP:          llm.Ptr(0.9),
+		TopK:          llm.Ptr(40),
+		MaxTokens:     llm.Ptr(128),
+		Stop:          []string{"\n\n"},
+		Seed:          llm.Ptr(42),
+		RepeatPenalty: llm.Ptr(1.1),
+		KeepAlive:     llm.Ptr(5 * time.Minute),
+	}
+	if _, err := client.ChatCompletion(context.Background(), opts); err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if ignored := client.IgnoredOptions(opts); len(ignored) != 0 {
+		t.Fatalf("unexpected ignored options: %v", ignored)
+	}
+
+	opts.Temperature = llm.Ptr(3.0)
+	if _, err := client.ChatCompletion(context.Background(), opts); err == nil {
+		t.Fatal("expected error for out of range temperature")
+	}
+}
+
+func TestOllamaChatCompletionTools(t *testing.T) {
+	server := newOllamaServer(t, func(req api.ChatRequest) []api.ChatResponse {
+		if len(req.Tools) != 1 || req.Tools[0].Function.Name != "http_request" {
+			t.Errorf("unexpected tools: %+v", req.Tools)
+		}
+		if prop, ok := req.Tools[0].Function.Parameters.Properties["url"]; !ok || prop.Type[0] != "string" {
+			t.Errorf("unexpected parameters: %+v", req.Tools[0].Function.Parameters)
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role == llm.RoleTool {
+			return []api.ChatResponse{{Message: api.Message{Role: llm.RoleAssistant, Content: "网页内容是 hello"}, Done: true, DoneReason: "stop"}}
+		}
+		return []api.ChatResponse{{
+			Message: api.Message{
+				Role: llm.RoleAssistant,
+				ToolCalls: []api.ToolCall{{Function: api.ToolCallFunction{
+					Name:      "http_request",
+					Arguments: api.ToolCallFunctionArguments{"url": "https://example.com"},
+				}}},
+			},
+			Done:       true,
+			DoneReason: "stop",
+		}}
+	})
+
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	opts := llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "请求 https://example.com"}},
+		Tools: []llm.Tool{{
+			Name:        "http_request",
+			Description: "http请求工具，用于请求网页，返回网页内容",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"url":{"type":"string","description":"网页地址"}},"required":["url"]}`),
+		}},
+	}
+	resp, err := client.ChatCompletion(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if resp.FinishReason != llm.FinishReasonToolCalls || len(resp.Message.ToolCalls) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	call := resp.Message.ToolCalls[0]
+	if call.Name != "http_request" || string(call.Arguments) != `{"url":"https://example.com"}` {
+		t.Fatalf("unexpected tool call: %+v", call)
+	}
+
+	opts.Messages = append(opts.Messages, resp.Message, llm.NewToolMessage(call, "hello"))
+	resp, err = client.ChatCompletion(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if resp.Message.Content != "网页内容是 hello" {
+		t.Fatalf("unexpected reply: %q", resp.Message.Content)
+	}
+}
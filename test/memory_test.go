@@ -0,0 +1,213 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/vectorstore"
+	"golang.org/x/sync/errgroup"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// TestMemoryStoreConcurrent 并发写入和检索，配合 go test -race 检查数据竞争
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	seed := []*vectorstore.Document{{Id: "seed", Text: "滚滚长江东逝水"}}
+	if err := store.AddDocuments(ctx, "sgyy", seed); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	const writers, readers, batches = 4, 8, 20
+	g, ctx := errgroup.WithContext(ctx)
+	for w := 0; w < writers; w++ {
+		g.Go(func() error {
+			for b := 0; b < batches; b++ {
+				docs := []*vectorstore.Document{
+					{Id: fmt.Sprintf("%d-%d-a", w, b), Text: fmt.Sprintf("第%d回 宴桃园豪杰三结义 %d", b, w)},
+					{Id: fmt.Sprintf("%d-%d-b", w, b), Text: fmt.Sprintf("第%d回 斩黄巾英雄首立功 %d", b, w)},
+				}
+				// 同时写入已有集合和新集合
+				if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+					return err
+				}
+				if err := store.AddDocuments(ctx, fmt.Sprintf("writer-%d", w), docs); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for r := 0; r < readers; r++ {
+		g.Go(func() error {
+			for i := 0; i < batches; i++ {
+				results, err := store.SimilaritySearch(ctx, "sgyy", "桃园结义", 5)
+				if err != nil {
+					return err
+				}
+				if len(results) == 0 {
+					return fmt.Errorf("no results")
+				}
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("并发读写失败: %v", err)
+	}
+
+	results, err := store.SimilaritySearch(context.Background(), "sgyy", "桃园结义", 1000)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	if want := 1 + writers*batches*2; len(results) != want {
+		t.Fatalf("expected %d records, got %d", want, len(results))
+	}
+}
+
+func TestMemoryStoreUpsertGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1, "book": "sgyy"}},
+		{Id: "2", Text: "斩黄巾英雄首立功", Metadata: map[string]any{"chapter": 1, "book": "sgyy"}},
+		{Id: "3", Text: "张翼德怒鞭督邮", Metadata: map[string]any{"chapter": 2, "book": "sgyy"}},
+	}
+	if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	// 重复写入同一个 ID 会替换原文档
+	fixed := []*vectorstore.Document{{Id: "3", Text: "张翼德怒鞭督邮 何国舅谋诛宦竖", Metadata: map[string]any{"chapter": 2, "book": "sgyy"}}}
+	if err := store.AddDocuments(ctx, "sgyy", fixed); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	got, err := store.GetDocuments(ctx, "sgyy", []string{"3", "404", "1"})
+	if err != nil {
+		t.Fatalf("GetDocuments 失败: %v", err)
+	}
+	if len(got) != 2 || got[0].Id != "3" || got[0].Text != fixed[0].Text || got[1].Id != "1" {
+		t.Fatalf("unexpected documents: %+v", got)
+	}
+	if results, _ := store.SimilaritySearch(ctx, "sgyy", "督邮", 10); len(results) != 3 {
+		t.Fatalf("expected 3 records after upsert, got %d", len(results))
+	}
+
+	if err := store.DeleteDocuments(ctx, "sgyy", []string{"2", "404"}); err != nil {
+		t.Fatalf("DeleteDocuments 失败: %v", err)
+	}
+	if err := store.DeleteByFilter(ctx, "sgyy", vectorstore.And(vectorstore.Eq("chapter", 2.0), vectorstore.Eq("book", "sgyy"))); err != nil {
+		t.Fatalf("DeleteByFilter 失败: %v", err)
+	}
+	results, err := store.SimilaritySearch(ctx, "sgyy", "桃园", 10)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "1" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	if err := store.DeleteDocuments(ctx, "missing", []string{"1"}); !errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStoreReservedKeys(t *testing.T) {
+	testReservedKeys(t, vectorstore.NewMemoryStore(newHashEmbedder(t)), "sgyy")
+}
+
+// testReservedKeys 检查元数据使用保留字段时写入被拒绝，MemoryStore 和 QdrantStore 共用
+func testReservedKeys(t *testing.T, store vectorstore.VectorStore, name string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, key := range []string{vectorstore.TextKey, vectorstore.NormalizedKey, vectorstore.ModelKey} {
+		docs := []*vectorstore.Document{{
+			Id:       "6f1c2a3e-0000-4000-8000-000000000002",
+			Text:     "张翼德怒鞭督邮",
+			Metadata: map[string]any{key: "x"},
+		}}
+		if err := store.AddDocuments(ctx, name, docs); !errors.Is(err, vectorstore.ErrReservedKey) {
+			t.Fatalf("expected ErrReservedKey for %s, got %v", key, err)
+		}
+	}
+}
+
+func TestMemoryStoreFilter(t *testing.T) {
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	testFilter(t, store, "sgyy", func(id string) string { return id })
+
+	ctx := context.Background()
+	if _, err := store.SimilaritySearch(ctx, "sgyy", "桃园", 5, vectorstore.WithFilter(vectorstore.And())); err == nil {
+		t.Fatal("expected error for empty filter")
+	}
+	for _, topK := range []int{0, -1} {
+		if _, err := store.SimilaritySearch(ctx, "sgyy", "桃园", topK); err == nil {
+			t.Fatalf("expected error for topK %d", topK)
+		}
+	}
+}
+
+// testFilter 在集合 name 中写入测试文档并检查各种过滤条件的结果，id 将测试文档的编号转换为向量存储使用的 ID
+// MemoryStore 和 QdrantStore 使用同一组用例，保证两者的过滤语义一致
+func testFilter(t *testing.T, store vectorstore.VectorStore, name string, id func(string) string) {
+	t.Helper()
+	ctx := context.Background()
+	docs := []*vectorstore.Document{
+		{Id: id("1"), Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1, "tags": []any{"刘备", "关羽", "张飞"}}},
+		{Id: id("2"), Text: "斩黄巾英雄首立功", Metadata: map[string]any{"chapter": 1, "tags": []any{"刘备"}}},
+		{Id: id("3"), Text: "张翼德怒鞭督邮", Metadata: map[string]any{"chapter": 2, "tags": []any{"张飞"}}},
+		{Id: id("4"), Text: "议温明董卓叱丁原", Metadata: map[string]any{"chapter": 3.0, "draft": true}},
+		{Id: id("5"), Text: "废汉帝陈留践位", Metadata: map[string]any{"chapter": int64(4), "draft": nil}},
+		{Id: id("6"), Text: "曹孟德谋杀董卓", Metadata: map[string]any{"chapter": 4.5, "tags": []string{"曹操"}, "scores": []int{7, 8}}},
+	}
+	if err := store.AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	gte, lt := 2.0, 4.0
+	cases := []struct {
+		name   string
+		filter *vectorstore.Filter
+		want   []string
+	}{
+		{"eq", vectorstore.Eq("chapter", 1), []string{"1", "2"}},
+		{"eq integral float", vectorstore.Eq("chapter", 3), []string{"4"}},
+		{"eq float", vectorstore.Eq("chapter", 2.0), []string{"3"}},
+		{"eq list", vectorstore.Eq("tags", "张飞"), []string{"1", "3"}},
+		{"eq typed list", vectorstore.Eq("tags", "曹操"), []string{"6"}},
+		{"eq int list", vectorstore.Eq("scores", 8), []string{"6"}},
+		{"in", vectorstore.In("chapter", 2, 4), []string{"3", "5"}},
+		{"in mixed", vectorstore.In("chapter", 3, 4.5), []string{"4", "6"}},
+		{"range", vectorstore.Range("chapter", vectorstore.RangeBounds{Gte: &gte, Lt: &lt}), []string{"3", "4"}},
+		{"range list", vectorstore.Between("scores", 8, 9), []string{"6"}},
+		{"between", vectorstore.Between("chapter", 1, 2), []string{"1", "2", "3"}},
+		{"exists", vectorstore.Exists("draft"), []string{"4"}},
+		{"or", vectorstore.Or(vectorstore.Eq("chapter", 4), vectorstore.Exists("tags")), []string{"1", "2", "3", "5", "6"}},
+		{"not", vectorstore.Not(vectorstore.Exists("tags")), []string{"4", "5"}},
+		{"and", vectorstore.And(vectorstore.Eq("tags", "刘备"), vectorstore.Not(vectorstore.Eq("tags", "关羽"))), []string{"2"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			want := make([]string, 0, len(tc.want))
+			for _, n := range tc.want {
+				want = append(want, id(n))
+			}
+			sort.Strings(want)
+			// topK 小于集合大小时也应返回全部满足条件的文档
+			results, err := store.SimilaritySearch(ctx, name, "桃园", len(tc.want), vectorstore.WithFilter(tc.filter))
+			if err != nil {
+				t.Fatalf("vector 查询失败: %v", err)
+			}
+			got := make([]string, 0, len(results))
+			for _, result := range results {
+				got = append(got, result.ID)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, want) {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"github.com/hl540/rag/documentloader"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/textsplitter"
+	"github.com/hl540/rag/vectorstore"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newHashEmbedder 创建不依赖网络的向量嵌入器
+func newHashEmbedder(t testing.TB) embedding.Embedder {
+	embedder, err := embedding.NewHashEmbedder(256)
+	if err != nil {
+		t.Fatalf("embedder 创建失败: %v", err)
+	}
+	return embedder
+}
+
+func TestHashEmbedder(t *testing.T) {
+	ctx := context.Background()
+	embedder := newHashEmbedder(t)
+	a, err := embedder.Embed(ctx, "三英战吕布")
+	if err != nil {
+		t.Fatalf("Embed 失败: %v", err)
+	}
+	b, _ := embedder.Embed(ctx, "三英战吕布")
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("expected stable output")
+	}
+
+	store := vectorstore.NewMemoryStore(embedder).(*vectorstore.MemoryStore)
+	near, _ := embedder.Embed(ctx, "刘关张三英战吕布于虎牢关")
+	far, _ := embedder.Embed(ctx, "诸葛亮草船借箭")
+	simNear, _ := store.CosineSimilarity(a, near)
+	simFar, _ := store.CosineSimilarity(a, far)
+	if simNear <= simFar {
+		t.Fatalf("expected related text to score higher: %f <= %f", simNear, simFar)
+	}
+}
+
+func TestOfflineRetrieval(t *testing.T) {
+	ctx := context.Background()
+	file, err := os.Open("../szbf.txt")
+	if err != nil {
+		t.Fatalf("文件读取失败：%s", err.Error())
+	}
+	defer file.Close()
+	splitter, err := textsplitter.NewSentenceSplitter(300, 30, true)
+	if err != nil {
+		t.Fatalf("分割器创建失败：%s", err.Error())
+	}
+	docs, err := documentloader.New(file).LoadSplit(splitter)
+	if err != nil {
+		t.Fatalf("文件加载失败：%s", err.Error())
+	}
+
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t))
+	if err := store.AddDocuments(ctx, "szbf", docs); err != nil {
+		t.Fatalf("向量化存储失败：%s", err.Error())
+	}
+	results, err := store.SimilaritySearch(ctx, "szbf", "怎样使敌人举国不战而降", 3)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	top := results[0].Text
+	if !strings.Contains(top, "不战而降") {
+		t.Fatalf("unexpected top result: %s", top)
+	}
+}
+
+func TestTextLoaderContentMetadata(t *testing.T) {
+	docs, err := documentloader.New(strings.NewReader("兵者，国之大事")).Load()
+	if err != nil {
+		t.Fatalf("文件加载失败：%s", err.Error())
+	}
+	if _, ok := docs[0].Metadata[vectorstore.ContentKey]; ok {
+		t.Fatalf("text should not be copied into metadata by default: %+v", docs[0].Metadata)
+	}
+
+	docs, err = documentloader.New(strings.NewReader("兵者，国之大事"), documentloader.WithContentMetadata()).Load()
+	if err != nil {
+		t.Fatalf("文件加载失败：%s", err.Error())
+	}
+	if docs[0].Metadata[vectorstore.ContentKey] != docs[0].Text {
+		t.Fatalf("expected text in metadata, got %+v", docs[0].Metadata)
+	}
+}
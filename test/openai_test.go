@@ -0,0 +1,223 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/llm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newOpenAIServer 启动一个模拟 OpenAI 兼容协议的测试服务
+func newOpenAIServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" || r.Header.Get("X-Tenant") != "rag" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		var req struct {
+			Model    string           `json:"model"`
+			Stream   bool             `json:"stream"`
+			Messages []map[string]any `json:"messages"`
+			Tools    []map[string]any `json:"tools"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if !req.Stream {
+			if len(req.Tools) > 0 && last["role"] == llm.RoleUser {
+				_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_abc","type":"function","function":{"name":"http_request","arguments":"{\"url\":\"https://example.com\"}"}}]},"finish_reason":"tool_calls"}]}`))
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"echo: %s"},"finish_reason":"stop"}]}`, last["content"])
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"choices":[{"delta":{"role":"assistant","content":"关羽"}}]}`,
+			`{"choices":[{"delta":{"content":"大意"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"vector_search","arguments":"{\"query\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"荆州\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		}
+		for _, chunk := range chunks {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", chunk)
+			w.(http.Flusher).Flush()
+		}
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 倒序返回，验证客户端按 index 还原顺序
+		data := make([]map[string]any, 0, len(req.Input))
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, map[string]any{"index": i, "embedding": []float32{float32(len([]rune(req.Input[i]))), 1}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenAIChatCompletion(t *testing.T) {
+	server := newOpenAIServer(t)
+	client, err := llm.NewOpenAI(server.URL+"/v1", "qwen3", llm.WithAPIKey("sk-test"), llm.WithHeader("X-Tenant", "rag"))
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	ctx := context.Background()
+	resp, err := client.ChatCompletion(ctx, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "你好"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if resp.Message.Content != "echo: 你好" || resp.FinishReason != "stop" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	opts := llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "请求 https://example.com"}},
+		Tools:    []llm.Tool{{Name: "http_request", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		TopK:     llm.Ptr(20),
+	}
+	resp, err = client.ChatCompletion(ctx, opts)
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0].ID != "call_abc" ||
+		string(resp.Message.ToolCalls[0].Arguments) != `{"url":"https://example.com"}` {
+		t.Fatalf("unexpected tool calls: %+v", resp.Message.ToolCalls)
+	}
+	if ignored := client.IgnoredOptions(opts); len(ignored) != 1 || ignored[0] != llm.OptionTopK {
+		t.Fatalf("unexpected ignored options: %v", ignored)
+	}
+
+	opts.Messages = append(opts.Messages, resp.Message, llm.NewToolMessage(resp.Message.ToolCalls[0], "hello"))
+	resp, err = client.ChatCompletion(ctx, opts)
+	if err != nil {
+		t.Fatalf("ChatCompletion 失败: %v", err)
+	}
+	if resp.Message.Content != "echo: hello" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestOpenAIChatCompletionStream(t *testing.T) {
+	server := newOpenAIServer(t)
+	client, err := llm.NewOpenAI(server.URL+"/v1", "qwen3", llm.WithAPIKey("sk-test"), llm.WithHeader("X-Tenant", "rag"))
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	var deltas []string
+	var final *llm.ChatCompletionChunk
+	err = client.ChatCompletionStream(context.Background(), llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "关羽是怎么失去荆州的"}},
+	}, func(chunk *llm.ChatCompletionChunk) error {
+		if chunk.Done {
+			final = chunk
+		} else {
+			deltas = append(deltas, chunk.Delta)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream 失败: %v", err)
+	}
+	if strings.Join(deltas, "|") != "关羽|大意" {
+		t.Fatalf("unexpected deltas: %q", deltas)
+	}
+	if final == nil || final.FinishReason != llm.FinishReasonToolCalls || final.Message.Content != "关羽大意" {
+		t.Fatalf("unexpected final chunk: %+v", final)
+	}
+	calls := final.Message.ToolCalls
+	if len(calls) != 1 || calls[0].ID != "call_1" || string(calls[0].Arguments) != `{"query":"荆州"}` {
+		t.Fatalf("unexpected tool calls: %+v", calls)
+	}
+}
+
+func TestOpenAIError(t *testing.T) {
+	server := newOpenAIServer(t)
+	client, err := llm.NewOpenAI(server.URL+"/v1", "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	_, err = client.ChatCompletion(context.Background(), llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "你好"}},
+	})
+	var apiErr *llm.OpenAIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "invalid api key" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server := newOpenAIServer(t)
+	embedder, err := embedding.NewOpenAIEmbedder(server.URL+"/v1", "bge-m3", embedding.WithOpenAIBatchSize(2))
+	if err != nil {
+		t.Fatalf("embedder 创建失败: %v", err)
+	}
+	embeds, err := embedder.Embeds(context.Background(), []string{"一", "二二", "三三三"})
+	if err != nil {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	for i, embed := range embeds {
+		if embed[0] != float32(i+1) {
+			t.Fatalf("embedding %d out of order: %v", i, embed)
+		}
+	}
+}
+
+func TestOpenAIMalformedResponses(t *testing.T) {
+	var toolIndex atomic.Int64
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprintf(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":%d,"id":"call_1","function":{"name":"vector_search"}}]}}]}`+"\n\n", toolIndex.Load())
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("/v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[1,0]},{"index":0,"embedding":[0,1]}]}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client, err := llm.NewOpenAI(server.URL+"/v1", "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	for _, index := range []int64{-1, 1, 1 << 40} {
+		toolIndex.Store(index)
+		err = client.ChatCompletionStream(context.Background(), llm.ChatCompletionOptions{
+			Messages: []llm.Message{{Role: llm.RoleUser, Content: "荆州"}},
+		}, func(chunk *llm.ChatCompletionChunk) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Fatalf("index %d: expected out of range error, got %v", index, err)
+		}
+	}
+
+	embedder, err := embedding.NewOpenAIEmbedder(server.URL+"/v1", "bge-m3")
+	if err != nil {
+		t.Fatalf("embedder 创建失败: %v", err)
+	}
+	if _, err := embedder.Embeds(context.Background(), []string{"一", "二"}); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("expected duplicate index error, got %v", err)
+	}
+}
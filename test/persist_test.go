@@ -0,0 +1,254 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/vectorstore"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryStorePersistence(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "宴桃园豪杰三结义", Metadata: map[string]any{"chapter": 1, "tags": []any{"刘备"}}},
+		{Id: "2", Text: "斩黄巾英雄首立功", Metadata: map[string]any{"chapter": 1}},
+		{Id: "3", Text: "张翼德怒鞭督邮"},
+	}
+	if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	if err := store.DeleteDocuments(ctx, "sgyy", []string{"2"}); err != nil {
+		t.Fatalf("DeleteDocuments 失败: %v", err)
+	}
+	want, err := store.SimilaritySearch(ctx, "sgyy", "桃园", 10)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("关闭存储失败: %v", err)
+	}
+
+	// 模拟写日志时进程崩溃，末尾留下不完整的条目
+	logFile, err := os.OpenFile(filepath.Join(dir, "append.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Write([]byte{0xff, 0x00, 0x00, 0x00, 0x01})
+	logFile.Close()
+
+	// 只依赖追加日志恢复
+	reopened, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	assertSameResults(t, reopened, want)
+	got, err := reopened.GetDocuments(ctx, "sgyy", []string{"1"})
+	if err != nil || len(got) != 1 || got[0].Metadata["chapter"] != 1.0 || got[0].Metadata["tags"].([]any)[0] != "刘备" {
+		t.Fatalf("unexpected documents: %+v, %v", got, err)
+	}
+
+	// 合并进快照后继续写入
+	if err := reopened.Compact(); err != nil {
+		t.Fatalf("Compact 失败: %v", err)
+	}
+	if err := reopened.DeleteByFilter(ctx, "sgyy", vectorstore.Eq("chapter", 1)); err != nil {
+		t.Fatalf("DeleteByFilter 失败: %v", err)
+	}
+	reopened.Close()
+	reopened, err = vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer reopened.Close()
+	if got, _ := reopened.GetDocuments(ctx, "sgyy", []string{"1", "3"}); len(got) != 1 || got[0].Id != "3" {
+		t.Fatalf("unexpected documents: %+v", got)
+	}
+}
+
+func TestMemoryStoreCompactCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+	admin := vectorstore.CollectionAdmin(store)
+	add := func(name string, ids ...string) {
+		docs := make([]*vectorstore.Document, 0, len(ids))
+		for _, id := range ids {
+			docs = append(docs, &vectorstore.Document{Id: id, Text: "第" + id + "回"})
+		}
+		if err := store.AddDocuments(ctx, name, docs); err != nil {
+			t.Fatalf("向量化存储失败: %v", err)
+		}
+	}
+	// 重放到快照之上会改变结果的操作序列
+	add("a", "1", "2")
+	if err := admin.RenameCollection(ctx, "a", "b"); err != nil {
+		t.Fatalf("RenameCollection 失败: %v", err)
+	}
+	add("a", "3")
+	add("c", "4")
+	add("d", "5")
+	if err := admin.DropCollection(ctx, "c"); err != nil {
+		t.Fatalf("DropCollection 失败: %v", err)
+	}
+	if err := admin.RenameCollection(ctx, "d", "c"); err != nil {
+		t.Fatalf("RenameCollection 失败: %v", err)
+	}
+
+	// 模拟 Compact 写入快照后、清空日志前进程崩溃：快照是新的，日志还是旧的
+	logPath := filepath.Join(dir, "append.log")
+	stale, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact 失败: %v", err)
+	}
+	store.Close()
+	if err := os.WriteFile(logPath, stale, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	want := map[string][]string{"a": {"3"}, "b": {"1", "2"}, "c": {"5"}}
+	for name, ids := range want {
+		if n, err := reopened.Count(ctx, name, nil); err != nil || n != len(ids) {
+			t.Fatalf("collection %s: expected %d documents, got %d, %v", name, len(ids), n, err)
+		}
+		if got, _ := reopened.GetDocuments(ctx, name, ids); len(got) != len(ids) {
+			t.Fatalf("collection %s: expected %v, got %+v", name, ids, got)
+		}
+	}
+	if ok, _ := reopened.CollectionExists(ctx, "d"); ok {
+		t.Fatal("collection d should have been renamed")
+	}
+
+	// 丢弃残留日志后的写入仍然可以恢复
+	if err := reopened.DeleteDocuments(ctx, "b", []string{"1"}); err != nil {
+		t.Fatalf("DeleteDocuments 失败: %v", err)
+	}
+	reopened.Close()
+	reopened, err = vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer reopened.Close()
+	if n, _ := reopened.Count(ctx, "b", nil); n != 1 {
+		t.Fatalf("expected 1 document in b, got %d", n)
+	}
+}
+
+func TestMemoryStoreSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sgyy.bin")
+	store := vectorstore.NewMemoryStore(newHashEmbedder(t)).(*vectorstore.MemoryStore)
+	docs := []*vectorstore.Document{
+		{Id: "1", Text: "宴桃园豪杰三结义"},
+		{Id: "2", Text: "斩黄巾英雄首立功"},
+	}
+	if err := store.AddDocuments(ctx, "sgyy", docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	want, _ := store.SimilaritySearch(ctx, "sgyy", "桃园", 10)
+	if err := store.Save(path); err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+
+	loaded := vectorstore.NewMemoryStore(newHashEmbedder(t)).(*vectorstore.MemoryStore)
+	if err := loaded.Load(path); err != nil {
+		t.Fatalf("Load 失败: %v", err)
+	}
+	assertSameResults(t, loaded, want)
+
+	// 快照记录了模型名称，换用不同参数的嵌入器时拒绝检索
+	other, err := embedding.NewHashEmbedder(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := vectorstore.NewMemoryStore(other).(*vectorstore.MemoryStore)
+	if err := mismatched.Load(path); err != nil {
+		t.Fatalf("Load 失败: %v", err)
+	}
+	if _, err := mismatched.SimilaritySearch(ctx, "sgyy", "桃园", 10); !errors.Is(err, vectorstore.ErrModelMismatch) {
+		t.Fatalf("expected ErrModelMismatch, got %v", err)
+	}
+
+	// 损坏的快照不会被读取
+	data, _ := os.ReadFile(path)
+	data[len(data)/2] ^= 0xff
+	os.WriteFile(path, data, 0o644)
+	if err := loaded.Load(path); !errors.Is(err, vectorstore.ErrCorruptSnapshot) {
+		t.Fatalf("expected ErrCorruptSnapshot, got %v", err)
+	}
+}
+
+func TestMemoryStoreLoadPersistent(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "sgyy.bin")
+	store, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("OpenMemoryStore 失败: %v", err)
+	}
+	if err := store.AddDocuments(ctx, "sgyy", []*vectorstore.Document{{Id: "1", Text: "宴桃园豪杰三结义"}}); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	want, _ := store.SimilaritySearch(ctx, "sgyy", "桃园", 10)
+	if err := store.Save(path); err != nil {
+		t.Fatalf("Save 失败: %v", err)
+	}
+	if err := store.AddDocuments(ctx, "sgyy", []*vectorstore.Document{{Id: "2", Text: "斩黄巾英雄首立功"}}); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	// Load 之后的内容和写入都要在重新打开后保留，Load 之前的日志不能被重放
+	if err := store.Load(path); err != nil {
+		t.Fatalf("Load 失败: %v", err)
+	}
+	assertSameResults(t, store, want)
+	if err := store.AddDocuments(ctx, "other", []*vectorstore.Document{{Id: "3", Text: "张翼德怒鞭督邮"}}); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := vectorstore.OpenMemoryStore(newHashEmbedder(t), dir)
+	if err != nil {
+		t.Fatalf("OpenMemoryStore 失败: %v", err)
+	}
+	defer reopened.Close()
+	assertSameResults(t, reopened, want)
+	if results, err := reopened.SimilaritySearch(ctx, "other", "督邮", 10); err != nil || len(results) != 1 {
+		t.Fatalf("expected write after Load to survive reopen: %v", err)
+	}
+}
+
+// assertSameResults 检查 store 对同一查询返回与 want 相同的结果
+func assertSameResults(t *testing.T, store vectorstore.VectorStore, want []*vectorstore.SearchResult) {
+	t.Helper()
+	got, err := store.SimilaritySearch(context.Background(), "sgyy", "桃园", 10)
+	if err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Score != want[i].Score {
+			t.Fatalf("result %d: expected %s(%f), got %s(%f)", i, want[i].ID, want[i].Score, got[i].ID, got[i].Score)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/vectorstore"
+	"github.com/joho/godotenv"
+	"github.com/qdrant/go-client/qdrant"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newQdrantStore 创建使用 HashEmbedder 的 QdrantStore，
+// 需要设置 QDRANT_HOST（可选 QDRANT_PORT，也可以写在 ../.env 中）指向可用的 Qdrant 服务，否则跳过测试
+func newQdrantStore(t *testing.T, opts ...vectorstore.QdrantOption) vectorstore.VectorStore {
+	t.Helper()
+	_ = godotenv.Load("../.env")
+	host := os.Getenv("QDRANT_HOST")
+	if host == "" {
+		t.Skip("QDRANT_HOST not set")
+	}
+	port := 6334
+	if p, err := strconv.Atoi(os.Getenv("QDRANT_PORT")); err == nil {
+		port = p
+	}
+	opts = append([]vectorstore.QdrantOption{
+		vectorstore.WithHost(host),
+		vectorstore.WithPort(port),
+		vectorstore.WithEmbedder(newHashEmbedder(t)),
+	}, opts...)
+	store, err := vectorstore.NewQdrantStore(opts...)
+	if err != nil {
+		t.Fatalf("Failed to create Qdrant store: %v", err)
+	}
+	return store
+}
+
+func TestQdrantStoreInvalidSchema(t *testing.T) {
+	if _, err := vectorstore.NewQdrantStore(vectorstore.WithDistance("hamming")); err == nil {
+		t.Fatal("expected error for unsupported distance")
+	}
+	if _, err := vectorstore.NewQdrantStore(vectorstore.WithDimension(-1)); err == nil {
+		t.Fatal("expected error for negative dimension")
+	}
+}
+
+func TestQdrantStoreInvalidTopK(t *testing.T) {
+	// topK 在访问服务之前校验，不需要可用的 Qdrant 服务
+	store, err := vectorstore.NewQdrantStore(vectorstore.WithEmbedder(newHashEmbedder(t)))
+	if err != nil {
+		t.Fatalf("Failed to create Qdrant store: %v", err)
+	}
+	for _, topK := range []int{0, -1} {
+		if _, err := store.SimilaritySearch(context.Background(), "sgyy", "桃园", topK); err == nil {
+			t.Fatalf("expected error for topK %d", topK)
+		}
+	}
+}
+
+func TestQdrantStoreSchemaMismatch(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("schema_%d", time.Now().UnixNano())
+	store := newQdrantStore(t,
+		vectorstore.WithDistance(vectorstore.DistanceDot),
+		vectorstore.WithHNSWIndex(vectorstore.HNSWConfig{M: 8, EfSearch: 32}),
+		vectorstore.WithScalarQuantization(false),
+		vectorstore.WithPayloadIndex("chapter", qdrant.FieldType_FieldTypeInteger),
+	)
+	t.Cleanup(func() {
+		_ = store.(vectorstore.CollectionAdmin).DropCollection(context.Background(), name)
+	})
+	docs := []*vectorstore.Document{{Id: "6f1c2a3e-0000-4000-8000-000000000002", Text: "桃园三结义", Metadata: map[string]any{"chapter": 1}}}
+	if err := store.AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	if _, err := store.SimilaritySearch(ctx, name, "桃园", 1); err != nil {
+		t.Fatalf("vector 查询失败: %v", err)
+	}
+
+	// 只检查显式设置的字段，未设置的字段沿用已有集合的配置
+	if err := newQdrantStore(t).AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	mismatched := [][]vectorstore.QdrantOption{
+		{vectorstore.WithDistance(vectorstore.DistanceCosine)},
+		{vectorstore.WithDimension(128)},
+		{vectorstore.WithHNSWIndex(vectorstore.HNSWConfig{M: 16})},
+		{vectorstore.WithBinaryQuantization(false)},
+		{vectorstore.WithPayloadIndex("chapter", qdrant.FieldType_FieldTypeKeyword)},
+	}
+	for i, opts := range mismatched {
+		if err := newQdrantStore(t, opts...).AddDocuments(ctx, name, docs); !errors.Is(err, vectorstore.ErrSchemaMismatch) {
+			t.Fatalf("case %d: expected ErrSchemaMismatch, got %v", i, err)
+		}
+	}
+	if _, err := newQdrantStore(t).SimilaritySearch(ctx, name+"_missing", "桃园", 1); !errors.Is(err, vectorstore.ErrCollectionNotFound) {
+		t.Fatalf("expected ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestQdrantStoreModelMismatch(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("model_%d", time.Now().UnixNano())
+	store := newQdrantStore(t)
+	t.Cleanup(func() {
+		_ = store.(vectorstore.CollectionAdmin).DropCollection(context.Background(), name)
+	})
+	docs := []*vectorstore.Document{{Id: "6f1c2a3e-0000-4000-8000-000000000003", Text: "桃园三结义"}}
+	if err := store.AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+
+	// 维度相同但模型不同的向量嵌入器不能写入或检索同一个集合
+	other, err := embedding.NewHashEmbedder(256, embedding.WithNGramRange(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := newQdrantStore(t, vectorstore.WithEmbedder(other))
+	if err := mismatched.AddDocuments(ctx, name, docs); !errors.Is(err, vectorstore.ErrModelMismatch) {
+		t.Fatalf("expected ErrModelMismatch, got %v", err)
+	}
+	if _, err := mismatched.SimilaritySearch(ctx, name, "桃园", 1); !errors.Is(err, vectorstore.ErrModelMismatch) {
+		t.Fatalf("expected ErrModelMismatch, got %v", err)
+	}
+}
+
+func TestQdrantStoreFilter(t *testing.T) {
+	name := fmt.Sprintf("filter_%d", time.Now().UnixNano())
+	store := newQdrantStore(t)
+	t.Cleanup(func() {
+		_ = store.(vectorstore.CollectionAdmin).DropCollection(context.Background(), name)
+	})
+	testFilter(t, store, name, func(id string) string {
+		return "6f1c2a3e-0000-4000-8000-00000000010" + id
+	})
+}
+
+func TestQdrantStoreScroll(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("scroll_%d", time.Now().UnixNano())
+	store := newQdrantStore(t)
+	t.Cleanup(func() {
+		_ = store.(vectorstore.CollectionAdmin).DropCollection(context.Background(), name)
+	})
+	docs := make([]*vectorstore.Document, 0, 5)
+	for i := 0; i < 5; i++ {
+		docs = append(docs, &vectorstore.Document{Id: fmt.Sprintf("6f1c2a3e-0000-4000-8000-00000000020%d", i), Text: fmt.Sprintf("第%d回", i)})
+	}
+	if err := store.AddDocuments(ctx, name, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	scroller := store.(vectorstore.Scroller)
+	page, err := scroller.ScrollDocuments(ctx, name, vectorstore.ScrollOptions{Limit: 2, WithEmbeddings: true})
+	if err != nil {
+		t.Fatalf("ScrollDocuments 失败: %v", err)
+	}
+	if len(page.Documents) != 2 || page.NextOffset != docs[2].Id || len(page.Documents[0].Embedding) != 256 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	// 小于等于 0 的 Limit 使用默认值
+	page, err = scroller.ScrollDocuments(ctx, name, vectorstore.ScrollOptions{Limit: -1})
+	if err != nil || len(page.Documents) != 5 || page.NextOffset != "" {
+		t.Fatalf("unexpected page: %+v, %v", page, err)
+	}
+}
@@ -0,0 +1,99 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hl540/rag/vectorstore"
+	"github.com/qdrant/go-client/qdrant"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// payloadConformance 是写入的元数据与读回的期望值，读回的数字统一为 int64 或 float64，
+// 列表统一为 []any，对象统一为 map[string]any
+var payloadConformance = []struct {
+	name  string
+	write any
+	read  any
+}{
+	{"nil", nil, nil},
+	{"bool", true, true},
+	{"string", "关云长", "关云长"},
+	{"int", 42, int64(42)},
+	{"int8", int8(-8), int64(-8)},
+	{"uint16", uint16(16), int64(16)},
+	{"int64", int64(1) << 60, int64(1) << 60},
+	{"float32", float32(0.5), 0.5},
+	{"float64", 3.14, 3.14},
+	{"list", []any{"a", 1, 2.5, false, nil}, []any{"a", int64(1), 2.5, false, nil}},
+	{"typed list", []string{"刘备", "关羽"}, []any{"刘备", "关羽"}},
+	{"int list", []int{1, 2}, []any{int64(1), int64(2)}},
+	{"object", map[string]any{"name": "张飞", "age": 30}, map[string]any{"name": "张飞", "age": int64(30)}},
+	{"typed object", map[string]float64{"x": 1.5}, map[string]any{"x": 1.5}},
+	{"nested", map[string]any{"tags": []any{map[string]any{"k": []int{1}}}}, map[string]any{"tags": []any{map[string]any{"k": []any{int64(1)}}}}},
+	{"pointer", func() *int { n := 7; return &n }(), int64(7)},
+	{"empty list", []any{}, []any{}},
+}
+
+func TestQdrantValueConformance(t *testing.T) {
+	for _, tc := range payloadConformance {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := vectorstore.ToQdrantValue(tc.write)
+			if err != nil {
+				t.Fatalf("ToQdrantValue 失败: %v", err)
+			}
+			got := vectorstore.FromQdrantValue(value)
+			if !reflect.DeepEqual(got, tc.read) {
+				t.Fatalf("expected %#v, got %#v", tc.read, got)
+			}
+			// 读回的值再次写入后保持不变
+			again, err := vectorstore.ToQdrantValue(got)
+			if err != nil {
+				t.Fatalf("ToQdrantValue 失败: %v", err)
+			}
+			if !reflect.DeepEqual(vectorstore.FromQdrantValue(again), tc.read) {
+				t.Fatalf("value changed after second round trip: %#v", again)
+			}
+		})
+	}
+
+	for _, bad := range []any{map[int]string{1: "a"}, uint64(1) << 63, "\xff", make(chan int)} {
+		if _, err := vectorstore.ToQdrantValue(bad); err == nil {
+			t.Fatalf("expected error for %#v", bad)
+		}
+	}
+	if got := vectorstore.FromQdrantValue(qdrant.NewValueNull()); got != nil {
+		t.Fatalf("expected nil, got %#v", got)
+	}
+}
+
+// TestQdrantPayloadRoundTrip 通过 AddDocuments 和 GetDocuments 验证元数据读回后保持一致
+func TestQdrantPayloadRoundTrip(t *testing.T) {
+	store := newQdrantStore(t)
+
+	ctx := context.Background()
+	collection := fmt.Sprintf("payload_%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		_ = store.(vectorstore.CollectionAdmin).DropCollection(context.Background(), collection)
+	})
+	metadata := make(map[string]any, len(payloadConformance))
+	want := make(map[string]any, len(payloadConformance))
+	for _, tc := range payloadConformance {
+		metadata[tc.name] = tc.write
+		want[tc.name] = tc.read
+	}
+	id := "6f1c2a3e-0000-4000-8000-000000000001"
+	docs := []*vectorstore.Document{{Id: id, Text: "桃园三结义", Metadata: metadata}}
+	if err := store.AddDocuments(ctx, collection, docs); err != nil {
+		t.Fatalf("向量化存储失败: %v", err)
+	}
+	got, err := store.GetDocuments(ctx, collection, []string{id})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("GetDocuments 失败: %+v, %v", got, err)
+	}
+	if got[0].Text != "桃园三结义" || !reflect.DeepEqual(got[0].Metadata, want) {
+		t.Fatalf("expected %#v, got %#v", want, got[0].Metadata)
+	}
+	testReservedKeys(t, store, collection)
+}
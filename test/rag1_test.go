@@ -8,42 +8,52 @@ import (
 	"github.com/hl540/rag/vectorstore"
 	"github.com/joho/godotenv"
 	"github.com/ollama/ollama/api"
-	"log"
+	"os"
+	"strings"
 	"testing"
 )
 
-func TestEmbedText(t *testing.T) {
-	if err := godotenv.Load("../.env"); err != nil {
-		log.Fatalf("加载 .env 失败: %v", err)
+// requireOllama 加载 ../.env 并返回 OLLAMA_HOST，没有配置 Ollama 服务时跳过测试
+func requireOllama(t *testing.T) string {
+	t.Helper()
+	_ = godotenv.Load("../.env")
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		t.Skip("OLLAMA_HOST not set")
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host
+}
+
+// newOllamaClient 创建连接真实 Ollama 服务的客户端，没有配置时跳过测试
+func newOllamaClient(t *testing.T) *api.Client {
+	t.Helper()
+	requireOllama(t)
+	client, err := api.ClientFromEnvironment()
+	if err != nil {
+		t.Fatalf("ollama 连接失败: %v", err)
 	}
+	return client
+}
 
-	llm, err := llm.New("http://127.0.0.1:11434", "snowflake-arctic-embed:33m")
+func TestEmbedText(t *testing.T) {
+	llm, err := llm.New(requireOllama(t), "snowflake-arctic-embed:33m")
 	if err != nil {
-		log.Fatalf("llm 客户端创建失败: %v", err)
+		t.Fatalf("llm 客户端创建失败: %v", err)
 	}
 	ctx := context.Background()
 	embed, err := llm.CreateEmbedding(ctx, "hello")
 	if err != nil {
-		log.Fatalf("llm embedding 失败: %v", err)
+		t.Fatalf("llm embedding 失败: %v", err)
 	}
 	t.Log(embed)
 }
 
 func TestVectorStoreAddDocument(t *testing.T) {
-	ollama, err := api.ClientFromEnvironment()
-	if err != nil {
-		log.Fatalf("ollama 连接失败: %v", err)
-	}
-
-	embedder := embedding.NewOllamaEmbedder(ollama, "snowflake-arctic-embed:33m")
-	vectorStore, err := vectorstore.NewQdrantStore(
-		vectorstore.WithHost("106.55.106.158"),
-		vectorstore.WithPort(6334),
-		vectorstore.WithEmbedder(embedder),
-	)
-	if err != nil {
-		log.Fatalf("qdrant 连接失败: %v", err)
-	}
+	embedder := embedding.NewOllamaEmbedder(newOllamaClient(t), "snowflake-arctic-embed:33m")
+	vectorStore := newQdrantStore(t, vectorstore.WithEmbedder(embedder))
 	ctx := context.Background()
 
 	texts := []*vectorstore.Document{
@@ -68,35 +78,20 @@ func TestVectorStoreAddDocument(t *testing.T) {
 			Metadata: make(map[string]any),
 		},
 	}
-	for i := range texts {
-		texts[i].Metadata[vectorstore.ContentKey] = texts[i].Text
-	}
-	err = vectorStore.AddDocuments(ctx, "test", texts)
+	err := vectorStore.AddDocuments(ctx, "test", texts)
 	if err != nil {
-		log.Fatalf("vector 新增失败: %v", err)
+		t.Fatalf("vector 新增失败: %v", err)
 	}
 	t.Log("success")
 }
 
 func TestVectorStoreSimilaritySearch(t *testing.T) {
-	ollama, err := api.ClientFromEnvironment()
-	if err != nil {
-		log.Fatalf("ollama 连接失败: %v", err)
-	}
-
-	embedder := embedding.NewOllamaEmbedder(ollama, "snowflake-arctic-embed:33m")
-	vectorStore, err := vectorstore.NewQdrantStore(
-		vectorstore.WithHost("106.55.106.158"),
-		vectorstore.WithPort(6334),
-		vectorstore.WithEmbedder(embedder),
-	)
-	if err != nil {
-		log.Fatalf("qdrant 连接失败: %v", err)
-	}
+	embedder := embedding.NewOllamaEmbedder(newOllamaClient(t), "snowflake-arctic-embed:33m")
+	vectorStore := newQdrantStore(t, vectorstore.WithEmbedder(embedder))
 	ctx := context.Background()
 	search, err := vectorStore.SimilaritySearch(ctx, "test", "直播带货", 5)
 	if err != nil {
-		log.Fatalf("vector 查询失败: %v", err)
+		t.Fatalf("vector 查询失败: %v", err)
 	}
 	for _, doc := range search {
 		t.Logf("ID:%s, Score:%f, Metadata:%+v", doc.ID, doc.Score, doc.Metadata)
@@ -5,17 +5,12 @@ import (
 	"github.com/hl540/rag/documentloader"
 	"github.com/hl540/rag/embedding"
 	"github.com/hl540/rag/vectorstore"
-	"github.com/ollama/ollama/api"
-	"log"
 	"os"
 	"testing"
 )
 
 func TestEmbed(t *testing.T) {
-	llm, err := api.ClientFromEnvironment()
-	if err != nil {
-		t.Fatalf("ollama 连接失败：%s", err.Error())
-	}
+	llm := newOllamaClient(t)
 
 	ctx := context.Background()
 	//vectorStore, err := qdrant.New(
@@ -33,11 +28,11 @@ func TestEmbed(t *testing.T) {
 	//docs, err := loader.LoadSplit(ctx, textsplitter.NewSentenceSplitter(500, 30, false))
 	docs, err := loader.Load()
 	if err != nil {
-		log.Fatalf("文件加载失败： %s", err.Error())
+		t.Fatalf("文件加载失败： %s", err.Error())
 	}
 	err = vectorStore.AddDocuments(ctx, "szbf", docs)
 	if err != nil {
-		log.Fatalf("向量化存储失败：%s", err.Error())
+		t.Fatalf("向量化存储失败：%s", err.Error())
 	}
 	t.Log("Success")
 	search, err := vectorStore.SimilaritySearch(ctx, "szbf", "孙子兵法“军形篇”讲了什么", 5)
@@ -0,0 +1,254 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"github.com/hl540/rag/llm"
+	"github.com/hl540/rag/resilience"
+	"github.com/ollama/ollama/api"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyEmbedder 前 failures 次调用返回 err
+type flakyEmbedder struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (e *flakyEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return nil, e.err
+	}
+	return []float32{1}, nil
+}
+
+func (e *flakyEmbedder) Embeds(ctx context.Context, texts []string) ([][]float32, error) {
+	embed, err := e.Embed(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	embeds := make([][]float32, len(texts))
+	for i := range embeds {
+		embeds[i] = embed
+	}
+	return embeds, nil
+}
+
+func newTestExecutor(t *testing.T, reports *[]resilience.Report, opts ...resilience.Option) *resilience.Executor {
+	var mu sync.Mutex
+	opts = append([]resilience.Option{
+		resilience.WithBackoff(time.Millisecond, 5*time.Millisecond, 2),
+		resilience.WithObserver(func(report resilience.Report) {
+			mu.Lock()
+			defer mu.Unlock()
+			*reports = append(*reports, report)
+		}),
+	}, opts...)
+	executor, err := resilience.New(opts...)
+	if err != nil {
+		t.Fatalf("executor 创建失败: %v", err)
+	}
+	return executor
+}
+
+func TestResilientEmbedderRetry(t *testing.T) {
+	var reports []resilience.Report
+	inner := &flakyEmbedder{failures: 2, err: api.StatusError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"}}
+	embedder := resilience.NewEmbedder(inner, newTestExecutor(t, &reports))
+
+	embeds, err := embedder.Embeds(context.Background(), []string{"兵者", "国之大事"})
+	if err != nil || len(embeds) != 2 {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Op != "Embeds" || reports[0].Attempts != 3 || reports[0].Err != nil {
+		t.Fatalf("unexpected reports: %+v", reports)
+	}
+
+	// 4xx 错误不重试
+	reports = nil
+	inner = &flakyEmbedder{failures: 1, err: api.StatusError{StatusCode: http.StatusBadRequest}}
+	embedder = resilience.NewEmbedder(inner, newTestExecutor(t, &reports))
+	if _, err := embedder.Embed(context.Background(), "兵者"); err == nil {
+		t.Fatal("expected fatal error")
+	}
+	if reports[0].Attempts != 1 {
+		t.Fatalf("fatal error should not be retried: %+v", reports)
+	}
+}
+
+func TestResilientEmbedderBatchRetry(t *testing.T) {
+	var requests atomic.Int64
+	var failed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// 包含 text-50 的批次第一次返回 503
+		if slices.Contains(req.Input, "text-50") && failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"error":"model is loading"}`))
+			return
+		}
+		embeds := make([][]float32, 0, len(req.Input))
+		for _, input := range req.Input {
+			var n float32
+			_, _ = fmt.Sscanf(input, "text-%f", &n)
+			embeds = append(embeds, []float32{n, 1})
+		}
+		_ = json.NewEncoder(w).Encode(api.EmbedResponse{Embeddings: embeds})
+	}))
+	defer server.Close()
+	base, _ := url.Parse(server.URL)
+	inner := embedding.NewOllamaEmbedder(api.NewClient(base, server.Client()), "bge-m3", embedding.WithBatchSize(16))
+
+	var reports []resilience.Report
+	embedder := resilience.NewEmbedder(inner, newTestExecutor(t, &reports))
+	texts := make([]string, 100)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("text-%d", i)
+	}
+	embeds, err := embedder.Embeds(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Embeds 失败: %v", err)
+	}
+	for i, embed := range embeds {
+		if embed[0] != float32(i) {
+			t.Fatalf("embedding %d out of order: %v", i, embed)
+		}
+	}
+	// 7 个批次加上失败批次的一次重试
+	if n := requests.Load(); n != 8 {
+		t.Fatalf("expected only the failed batch to be retried, got %d requests", n)
+	}
+}
+
+func TestResilienceCircuitBreaker(t *testing.T) {
+	var reports []resilience.Report
+	breaker, err := resilience.NewBreaker(3, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("熔断器创建失败: %v", err)
+	}
+	inner := &flakyEmbedder{failures: 3, err: syscall.ECONNREFUSED}
+	embedder := resilience.NewEmbedder(inner, newTestExecutor(t, &reports,
+		resilience.WithMaxAttempts(5),
+		resilience.WithCircuitBreaker(breaker),
+	))
+
+	// 连续失败 3 次后熔断，第 4 次尝试被直接拒绝
+	_, err = embedder.Embed(context.Background(), "兵者")
+	if !errors.Is(err, resilience.ErrCircuitOpen) || inner.calls != 3 {
+		t.Fatalf("expected circuit open after 3 calls, got %v after %d calls", err, inner.calls)
+	}
+	// 保留最后一次调用的错误
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected last error to be kept, got %v", err)
+	}
+	if breaker.State() != resilience.StateOpen {
+		t.Fatalf("unexpected state: %s", breaker.State())
+	}
+
+	// 冷却结束后放行试探调用，成功则关闭
+	time.Sleep(60 * time.Millisecond)
+	if _, err := embedder.Embed(context.Background(), "兵者"); err != nil {
+		t.Fatalf("expected probe to succeed: %v", err)
+	}
+	if breaker.State() != resilience.StateClosed {
+		t.Fatalf("unexpected state: %s", breaker.State())
+	}
+}
+
+func TestResilienceBreakerNonRetryable(t *testing.T) {
+	var reports []resilience.Report
+	breaker, err := resilience.NewBreaker(3, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("熔断器创建失败: %v", err)
+	}
+	executor := newTestExecutor(t, &reports, resilience.WithMaxAttempts(1), resilience.WithCircuitBreaker(breaker))
+	fail := func(err error) func(ctx context.Context) error {
+		return func(ctx context.Context) error { return err }
+	}
+	ctx := context.Background()
+
+	// 4xx 错误不清零连续失败次数
+	_ = executor.Do(ctx, "op", fail(syscall.ECONNREFUSED))
+	_ = executor.Do(ctx, "op", fail(syscall.ECONNREFUSED))
+	_ = executor.Do(ctx, "op", fail(api.StatusError{StatusCode: http.StatusBadRequest}))
+	_ = executor.Do(ctx, "op", fail(syscall.ECONNREFUSED))
+	if breaker.State() != resilience.StateOpen {
+		t.Fatalf("expected open after 3 failures, got %s", breaker.State())
+	}
+
+	// 半开状态下试探调用被取消或返回 4xx 时不关闭熔断器，下一次调用可以继续试探
+	time.Sleep(60 * time.Millisecond)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_ = executor.Do(canceled, "op", func(ctx context.Context) error { return ctx.Err() })
+	if breaker.State() != resilience.StateHalfOpen {
+		t.Fatalf("canceled probe should not close the breaker, got %s", breaker.State())
+	}
+	_ = executor.Do(ctx, "op", fail(api.StatusError{StatusCode: http.StatusBadRequest}))
+	if breaker.State() != resilience.StateHalfOpen {
+		t.Fatalf("4xx probe should not close the breaker, got %s", breaker.State())
+	}
+	if err := executor.Do(ctx, "op", fail(nil)); err != nil {
+		t.Fatalf("expected probe to be allowed: %v", err)
+	}
+	if breaker.State() != resilience.StateClosed {
+		t.Fatalf("unexpected state: %s", breaker.State())
+	}
+}
+
+func TestResilientLLMStream(t *testing.T) {
+	var reports []resilience.Report
+	model := &scriptedLLM{replies: []llm.Message{{Role: llm.RoleAssistant, Content: "ok"}}}
+	client := resilience.NewLLM(model, newTestExecutor(t, &reports))
+
+	// 已经输出过分片后出错不会重试
+	boom := errors.New("boom")
+	err := client.ChatCompletionStream(context.Background(), llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+	}, func(chunk *llm.ChatCompletionChunk) error {
+		return errors.Join(boom, context.DeadlineExceeded)
+	})
+	if !errors.Is(err, boom) || reports[0].Attempts != 1 {
+		t.Fatalf("unexpected result: %v, %+v", err, reports)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{api.StatusError{StatusCode: http.StatusInternalServerError}, true},
+		{&llm.OpenAIError{StatusCode: http.StatusTooManyRequests}, true},
+		{&llm.OpenAIError{StatusCode: http.StatusUnauthorized}, false},
+		{syscall.ECONNREFUSED, true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{errors.New("empty text"), false},
+	}
+	for _, c := range cases {
+		if got := resilience.IsRetryable(c.err); got != c.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
@@ -4,16 +4,12 @@ import (
 	"context"
 	"github.com/hl540/rag/embedding"
 	"github.com/hl540/rag/vectorstore"
-	"github.com/ollama/ollama/api"
 	"testing"
 )
 
 func TestSGYYSearch(t *testing.T) {
 	// 1. 初始化 Ollama 客户端
-	llm, err := api.ClientFromEnvironment()
-	if err != nil {
-		t.Fatalf("Failed to create Ollama client: %v", err)
-	}
+	llm := newOllamaClient(t)
 
 	// 2. 创建文本分割器 - 使用较小的块大小以保持上下文完整性
 	//splitter, err := textsplitter.NewSentenceSplitter(200, 20, true)
@@ -26,14 +22,7 @@ func TestSGYYSearch(t *testing.T) {
 	embedder := embedding.NewOllamaEmbedder(llm, "quentinz/bge-base-zh-v1.5:latest")
 
 	// 4. 创建 Qdrant 向量存储
-	store, err := vectorstore.NewQdrantStore(
-		vectorstore.WithHost("106.55.106.158"),
-		vectorstore.WithPort(6334),
-		vectorstore.WithEmbedder(embedder),
-	)
-	if err != nil {
-		t.Fatalf("Failed to create Qdrant store: %v", err)
-	}
+	store := newQdrantStore(t, vectorstore.WithEmbedder(embedder))
 
 	// 5. 加载《孙子兵法》文档
 	//file, err := os.Open("../三国演义.txt")
@@ -75,7 +64,7 @@ func TestSGYYSearch(t *testing.T) {
 
 			t.Logf("\nSearch results for query '%s':", tc)
 			for i, result := range results {
-				t.Logf("Result %d: Score=%.4f, Content: %s", i+1, result.Score, result.Metadata["content"])
+				t.Logf("Result %d: Score=%.4f, Content: %s", i+1, result.Score, result.Text)
 			}
 		})
 	}
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hl540/rag/llm"
+	"github.com/ollama/ollama/api"
+	"testing"
+)
+
+type relevanceGrade struct {
+	Relevant bool     `json:"relevant" description:"段落是否与问题相关"`
+	Score    int      `json:"score"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := llm.SchemaOf(relevanceGrade{})
+	if err != nil {
+		t.Fatalf("SchemaOf 失败: %v", err)
+	}
+	want := `{"properties":{"keywords":{"items":{"type":"string"},"type":"array"},"relevant":{"description":"段落是否与问题相关","type":"boolean"},"score":{"type":"integer"}},"required":["relevant","score"],"type":"object"}`
+	if string(schema) != want {
+		t.Fatalf("unexpected schema: %s", schema)
+	}
+	if err := llm.ValidateJSON(schema, []byte(`{"relevant":true,"score":3}`)); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+	if err := llm.ValidateJSON(schema, []byte(`{"relevant":"yes","score":3}`)); err == nil {
+		t.Fatal("expected type error")
+	}
+	if err := llm.ValidateJSON(schema, []byte(`{"relevant":true}`)); err == nil {
+		t.Fatal("expected missing property error")
+	}
+}
+
+func TestChatCompletionJSON(t *testing.T) {
+	model := &scriptedLLM{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "相关"},
+		{Role: llm.RoleAssistant, Content: `{"relevant":true}`},
+		{Role: llm.RoleAssistant, Content: "```json\n{\"relevant\":true,\"score\":2,\"keywords\":[\"赤壁\"]}\n```"},
+	}}
+	grade, err := llm.ChatCompletionJSON[relevanceGrade](context.Background(), model, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "判断段落是否与赤壁之战相关"}},
+	}, 2)
+	if err != nil {
+		t.Fatalf("ChatCompletionJSON 失败: %v", err)
+	}
+	if !grade.Relevant || grade.Score != 2 || len(grade.Keywords) != 1 {
+		t.Fatalf("unexpected grade: %+v", grade)
+	}
+	if len(model.requests) != 3 || model.requests[0].ResponseFormat == nil {
+		t.Fatalf("unexpected requests: %+v", model.requests)
+	}
+	// 每次重试都会带上上一次的回复和错误说明
+	if n := len(model.requests[2].Messages); n != 5 {
+		t.Fatalf("unexpected message count on last attempt: %d", n)
+	}
+
+	model = &scriptedLLM{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "{}"},
+		{Role: llm.RoleAssistant, Content: "{}"},
+	}}
+	_, err = llm.ChatCompletionJSON[relevanceGrade](context.Background(), model, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+	}, 1)
+	if err == nil {
+		t.Fatal("expected error after retries are exhausted")
+	}
+}
+
+type gradeBase struct {
+	Score int    `json:"score"`
+	Note  string `json:"note,omitempty"`
+}
+
+type detailedGrade struct {
+	gradeBase
+	*relevanceGrade
+	Raw  []byte `json:"raw,omitempty"`
+	Note string `json:"note"`
+}
+
+func TestSchemaOfEmbedded(t *testing.T) {
+	schema, err := llm.SchemaOf(detailedGrade{})
+	if err != nil {
+		t.Fatalf("SchemaOf 失败: %v", err)
+	}
+	// 与 encoding/json 一致：展开匿名结构体，note 取层级较浅的字段，同一层级冲突的 score 被忽略，
+	// 嵌入指针的字段不是必填，[]byte 为 base64 字符串
+	want := `{"properties":{"keywords":{"items":{"type":"string"},"type":"array"},"note":{"type":"string"},"raw":{"contentEncoding":"base64","type":"string"},"relevant":{"description":"段落是否与问题相关","type":"boolean"}},"required":["note"],"type":"object"}`
+	if string(schema) != want {
+		t.Fatalf("unexpected schema: %s", schema)
+	}
+	data, err := json.Marshal(detailedGrade{
+		gradeBase:      gradeBase{Score: 1},
+		relevanceGrade: &relevanceGrade{Relevant: true},
+		Raw:            []byte("赤壁"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := llm.ValidateJSON(schema, data); err != nil {
+		t.Fatalf("marshaled value does not match schema: %v, %s", err, data)
+	}
+}
+
+type countedKeywords struct {
+	Keywords []string `json:"keywords,omitempty"`
+	Count    uint8    `json:"count"`
+}
+
+func TestChatCompletionJSONFreshResult(t *testing.T) {
+	// 第一次回复符合 Schema 但解析时溢出，解析了一半的 keywords 不能带到下一次
+	model := &scriptedLLM{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: `{"keywords":["赤壁"],"count":300}`},
+		{Role: llm.RoleAssistant, Content: `{"count":1}`},
+	}}
+	result, err := llm.ChatCompletionJSON[countedKeywords](context.Background(), model, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+	}, 1)
+	if err != nil {
+		t.Fatalf("ChatCompletionJSON 失败: %v", err)
+	}
+	if result.Count != 1 || result.Keywords != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestOllamaChatCompletionFormat(t *testing.T) {
+	schema, err := llm.SchemaOf(relevanceGrade{})
+	if err != nil {
+		t.Fatalf("SchemaOf 失败: %v", err)
+	}
+	server := newOllamaServer(t, func(req api.ChatRequest) []api.ChatResponse {
+		var got, want any
+		_ = json.Unmarshal(req.Format, &got)
+		_ = json.Unmarshal(schema, &want)
+		if !jsonEqual(got, want) {
+			t.Errorf("unexpected format: %s", req.Format)
+		}
+		return []api.ChatResponse{{Message: api.Message{Role: llm.RoleAssistant, Content: `{"relevant":false,"score":0}`}, Done: true}}
+	})
+	client, err := llm.New(server.URL, "qwen3")
+	if err != nil {
+		t.Fatalf("llm 客户端创建失败: %v", err)
+	}
+	grade, err := llm.ChatCompletionJSON[relevanceGrade](context.Background(), client, llm.ChatCompletionOptions{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+	}, 0)
+	if err != nil || grade.Relevant {
+		t.Fatalf("unexpected result: %+v, %v", grade, err)
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -0,0 +1,216 @@
+package vectorstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// exportVersion 是导出文件格式的版本号
+const exportVersion = 1
+
+// ScrollFunc 处理 ScrollAll 读到的每个文档，返回错误时停止遍历
+type ScrollFunc func(doc *Document) error
+
+// ScrollAll 从头到尾逐页遍历集合中的文档，opts.Offset 会被忽略
+func ScrollAll(ctx context.Context, s Scroller, name string, opts ScrollOptions, fn ScrollFunc) error {
+	opts.Offset = ""
+	for {
+		page, err := s.ScrollDocuments(ctx, name, opts)
+		if err != nil {
+			return err
+		}
+		for _, doc := range page.Documents {
+			if err := fn(doc); err != nil {
+				return err
+			}
+		}
+		if page.NextOffset == "" {
+			return nil
+		}
+		opts.Offset = page.NextOffset
+	}
+}
+
+// exportHeader 是导出文件的第一行，记录集合的向量配置，导入时用于检查目标向量存储是否兼容
+type exportHeader struct {
+	Version    int      `json:"version"`
+	Collection string   `json:"collection"`
+	Model      string   `json:"model,omitempty"`
+	Normalized bool     `json:"normalized"`
+	Dimension  int      `json:"dimension"`
+	Distance   Distance `json:"distance"`
+}
+
+// exportDocument 是导出文件中的一个文档
+type exportDocument struct {
+	Id        string         `json:"id"`
+	Text      string         `json:"text"`
+	Embedding []float32      `json:"embedding"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// Export 将集合中的全部文档连同向量以 JSONL 格式写入 w：
+// 第一行是集合的向量配置，之后每行一个文档，包含 ID、文本、向量和元数据。
+// store 需要同时实现 Scroller 和 CollectionAdmin，否则返回 ErrNotSupported
+func Export(ctx context.Context, store VectorStore, name string, w io.Writer) error {
+	scroller, ok := store.(Scroller)
+	if !ok {
+		return ErrNotSupported
+	}
+	admin, ok := store.(CollectionAdmin)
+	if !ok {
+		return ErrNotSupported
+	}
+	info, err := admin.CollectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	header := exportHeader{
+		Version:    exportVersion,
+		Collection: name,
+		Model:      info.Model,
+		Normalized: info.Normalized,
+		Dimension:  info.Dimension,
+		Distance:   info.Distance,
+	}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	err = ScrollAll(ctx, scroller, name, ScrollOptions{WithEmbeddings: true}, func(doc *Document) error {
+		return enc.Encode(exportDocument{
+			Id:        doc.Id,
+			Text:      doc.Text,
+			Embedding: doc.Embedding,
+			Metadata:  doc.Metadata,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import 读取 Export 写出的文件，将文档连同向量写入集合 name，不重新生成向量嵌入，返回写入的文档数量
+// 文件中的模型名称、归一化状态和维度与目标集合不一致时返回错误，新建的集合会被删除。
+// 元数据中的整数读回为 int64，其余数字为 float64
+func Import(ctx context.Context, store VectorStore, name string, r io.Reader) (int, error) {
+	admin, ok := store.(CollectionAdmin)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var header exportHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("vectorstore: read export header: %w", err)
+	}
+	if header.Version != exportVersion {
+		return 0, fmt.Errorf("vectorstore: unsupported export version %d", header.Version)
+	}
+
+	exists, err := admin.CollectionExists(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	if exists {
+		if err := checkImport(ctx, admin, name, header); err != nil {
+			return 0, err
+		}
+	}
+
+	const batchSize = 100
+	count := 0
+	batch := make([]*Document, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := store.AddDocuments(ctx, name, batch); err != nil {
+			return err
+		}
+		// 新建的集合在写入第一批文档后才有向量配置
+		if !exists {
+			exists = true
+			if err := checkImport(ctx, admin, name, header); err != nil {
+				return errors.Join(err, admin.DropCollection(ctx, name))
+			}
+		}
+		count += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		var doc exportDocument
+		if err := dec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return count, fmt.Errorf("vectorstore: read document %d: %w", count+len(batch)+1, err)
+		}
+		if len(doc.Embedding) == 0 {
+			return count, fmt.Errorf("vectorstore: document %s has no embedding", doc.Id)
+		}
+		metadata, _ := fromJSONNumbers(doc.Metadata).(map[string]any)
+		batch = append(batch, &Document{
+			Id:        doc.Id,
+			Text:      doc.Text,
+			Metadata:  metadata,
+			Embedding: doc.Embedding,
+		})
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, flush()
+}
+
+// checkImport 检查导出文件的向量配置与集合是否兼容，任一方未知的字段不检查
+func checkImport(ctx context.Context, admin CollectionAdmin, name string, header exportHeader) error {
+	info, err := admin.CollectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	if header.Model != "" && info.Model != "" && header.Model != info.Model {
+		return fmt.Errorf("%w: export uses %s, collection uses %s", ErrModelMismatch, header.Model, info.Model)
+	}
+	if info.Count > 0 && header.Normalized != info.Normalized {
+		return ErrNormalizationMismatch
+	}
+	if header.Dimension != 0 && info.Dimension != 0 && header.Dimension != info.Dimension {
+		return fmt.Errorf("%w: export has %d dimensions, collection has %d", ErrSchemaMismatch, header.Dimension, info.Dimension)
+	}
+	return nil
+}
+
+// fromJSONNumbers 将 json.Number 转换为 int64 或 float64
+func fromJSONNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(v.String(), ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = fromJSONNumbers(item)
+		}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = fromJSONNumbers(item)
+		}
+	}
+	return v
+}
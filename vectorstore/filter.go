@@ -0,0 +1,353 @@
+package vectorstore
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/qdrant/go-client/qdrant"
+	"reflect"
+)
+
+type filterOp int
+
+const (
+	opEq filterOp = iota
+	opIn
+	opRange
+	opExists
+	opAnd
+	opOr
+	opNot
+)
+
+// Filter 是与后端无关的元数据过滤表达式，使用 Eq、In、Range、Exists、And、Or、Not 等函数构造
+// 元数据的值为列表时，Eq、In 和 Range 只要列表中任意一个元素满足条件即匹配，与 Qdrant 的语义一致
+type Filter struct {
+	op       filterOp
+	key      string
+	values   []any
+	bounds   RangeBounds
+	children []*Filter
+}
+
+// RangeBounds 是数值范围，nil 表示不限制
+type RangeBounds struct {
+	Gt  *float64
+	Gte *float64
+	Lt  *float64
+	Lte *float64
+}
+
+// Eq 匹配元数据中 key 的值等于 value 的文档，value 可以是字符串、布尔值或数字
+func Eq(key string, value any) *Filter {
+	return &Filter{op: opEq, key: key, values: []any{value}}
+}
+
+// In 匹配元数据中 key 的值等于 values 中任意一个的文档
+func In(key string, values ...any) *Filter {
+	return &Filter{op: opIn, key: key, values: values}
+}
+
+// Range 匹配元数据中 key 的数值落在 bounds 范围内的文档
+func Range(key string, bounds RangeBounds) *Filter {
+	return &Filter{op: opRange, key: key, bounds: bounds}
+}
+
+// Between 匹配元数据中 key 的数值在 [min, max] 之间的文档
+func Between(key string, min, max float64) *Filter {
+	return Range(key, RangeBounds{Gte: &min, Lte: &max})
+}
+
+// Exists 匹配元数据中存在 key 且值不为空的文档，null 和空列表视为不存在
+func Exists(key string) *Filter {
+	return &Filter{op: opExists, key: key}
+}
+
+// And 匹配同时满足所有条件的文档
+func And(filters ...*Filter) *Filter {
+	return &Filter{op: opAnd, children: filters}
+}
+
+// Or 匹配满足任意一个条件的文档
+func Or(filters ...*Filter) *Filter {
+	return &Filter{op: opOr, children: filters}
+}
+
+// Not 匹配不满足条件的文档
+func Not(filter *Filter) *Filter {
+	return &Filter{op: opNot, children: []*Filter{filter}}
+}
+
+// Validate 检查过滤表达式是否合法
+func (f *Filter) Validate() error {
+	if f == nil {
+		return errors.New("nil filter")
+	}
+	switch f.op {
+	case opEq, opIn:
+		if f.key == "" {
+			return errors.New("filter: empty key")
+		}
+		if len(f.values) == 0 {
+			return fmt.Errorf("filter: empty values for key %s", f.key)
+		}
+		for _, value := range f.values {
+			if !isScalar(value) {
+				return fmt.Errorf("filter: unsupported value type %T for key %s", value, f.key)
+			}
+		}
+	case opRange:
+		if f.key == "" {
+			return errors.New("filter: empty key")
+		}
+		b := f.bounds
+		if b.Gt == nil && b.Gte == nil && b.Lt == nil && b.Lte == nil {
+			return fmt.Errorf("filter: empty range for key %s", f.key)
+		}
+	case opExists:
+		if f.key == "" {
+			return errors.New("filter: empty key")
+		}
+	case opAnd, opOr, opNot:
+		if len(f.children) == 0 {
+			return errors.New("filter: empty children")
+		}
+		for _, child := range f.children {
+			if err := child.Validate(); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("filter: unsupported op %d", f.op)
+	}
+	return nil
+}
+
+// Match 判断元数据是否满足过滤条件
+func (f *Filter) Match(metadata map[string]any) bool {
+	switch f.op {
+	case opEq, opIn:
+		for _, v := range elements(metadata[f.key]) {
+			for _, want := range f.values {
+				if equal(v, want) {
+					return true
+				}
+			}
+		}
+		return false
+	case opRange:
+		for _, v := range elements(metadata[f.key]) {
+			if n, ok := toFloat(v); ok && f.bounds.contains(n) {
+				return true
+			}
+		}
+		return false
+	case opExists:
+		return len(elements(metadata[f.key])) > 0
+	case opAnd:
+		for _, child := range f.children {
+			if !child.Match(metadata) {
+				return false
+			}
+		}
+		return true
+	case opOr:
+		for _, child := range f.children {
+			if child.Match(metadata) {
+				return true
+			}
+		}
+		return false
+	case opNot:
+		return !f.children[0].Match(metadata)
+	}
+	return false
+}
+
+func (b RangeBounds) contains(n float64) bool {
+	return (b.Gt == nil || n > *b.Gt) &&
+		(b.Gte == nil || n >= *b.Gte) &&
+		(b.Lt == nil || n < *b.Lt) &&
+		(b.Lte == nil || n <= *b.Lte)
+}
+
+// elements 将元数据的值展开为元素列表，nil 返回空列表
+// 与 ToQdrantValue 的存储方式一致：任意元素类型的切片和数组都展开，指针取其指向的值，[]byte 视为 base64 字符串
+func elements(v any) []any {
+	v = deref(v)
+	switch list := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return []any{base64.StdEncoding.EncodeToString(list)}
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return []any{v}
+	}
+	result := make([]any, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if item := deref(rv.Index(i).Interface()); item != nil {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// deref 返回指针指向的值，nil 指针返回 nil
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// isScalar 判断值是否为字符串、布尔值或数字
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, bool:
+		return true
+	}
+	_, ok := toFloat(v)
+	return ok
+}
+
+// equal 比较两个元数据值，数字按数值比较，不区分整数和浮点数类型
+func equal(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return a == b
+}
+
+// toFloat 将数字类型转换为 float64
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// toQdrantFilter 将过滤表达式转换为 qdrant.Filter
+func toQdrantFilter(f *Filter) (*qdrant.Filter, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	filter := &qdrant.Filter{}
+	switch f.op {
+	case opAnd, opOr, opNot:
+		conditions := make([]*qdrant.Condition, 0, len(f.children))
+		for _, child := range f.children {
+			condition, err := toQdrantCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		switch f.op {
+		case opAnd:
+			filter.Must = conditions
+		case opOr:
+			filter.Should = conditions
+		case opNot:
+			filter.MustNot = conditions
+		}
+	default:
+		condition, err := toQdrantCondition(f)
+		if err != nil {
+			return nil, err
+		}
+		filter.Must = []*qdrant.Condition{condition}
+	}
+	return filter, nil
+}
+
+// toQdrantCondition 将过滤表达式转换为 qdrant.Condition
+func toQdrantCondition(f *Filter) (*qdrant.Condition, error) {
+	switch f.op {
+	case opEq:
+		return matchCondition(f.key, f.values[0]), nil
+	case opIn:
+		return inCondition(f.key, f.values), nil
+	case opRange:
+		return qdrant.NewRange(f.key, &qdrant.Range{
+			Gt:  f.bounds.Gt,
+			Gte: f.bounds.Gte,
+			Lt:  f.bounds.Lt,
+			Lte: f.bounds.Lte,
+		}), nil
+	case opExists:
+		return qdrant.NewFilterAsCondition(&qdrant.Filter{
+			MustNot: []*qdrant.Condition{qdrant.NewIsEmpty(f.key)},
+		}), nil
+	case opAnd, opOr, opNot:
+		filter, err := toQdrantFilter(f)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewFilterAsCondition(filter), nil
+	}
+	return nil, fmt.Errorf("filter: unsupported op %d", f.op)
+}
+
+// matchCondition 构造单个值的相等条件
+// 数字使用上下界相同的范围条件，与 Match 一样按数值比较，同时匹配 Qdrant 中的整数和浮点数
+func matchCondition(key string, value any) *qdrant.Condition {
+	switch v := value.(type) {
+	case string:
+		return qdrant.NewMatchKeyword(key, v)
+	case bool:
+		return qdrant.NewMatchBool(key, v)
+	}
+	n, _ := toFloat(value)
+	return qdrant.NewRange(key, &qdrant.Range{Gte: &n, Lte: &n})
+}
+
+// inCondition 构造多个值的相等条件，值都是字符串时使用 Qdrant 的 keywords 匹配
+func inCondition(key string, values []any) *qdrant.Condition {
+	keywords := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			keywords = append(keywords, s)
+		}
+	}
+	if len(keywords) == len(values) {
+		return qdrant.NewMatchKeywords(key, keywords...)
+	}
+	conditions := make([]*qdrant.Condition, 0, len(values))
+	for _, value := range values {
+		conditions = append(conditions, matchCondition(key, value))
+	}
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{Should: conditions})
+}
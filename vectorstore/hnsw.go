@@ -0,0 +1,305 @@
+package vectorstore
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// HNSWConfig 是 HNSW 近似最近邻索引的参数，为 0 的字段使用默认值
+type HNSWConfig struct {
+	M              int // 每个节点在每层的最大邻居数，第 0 层为 2M，默认为 16
+	EfConstruction int // 插入时候选集合的大小，越大索引质量越高、插入越慢，默认为 200
+	EfSearch       int // 检索时候选集合的大小，越大召回率越高、检索越慢，默认为 64，小于 topK 时使用 topK
+}
+
+func (c HNSWConfig) withDefaults() HNSWConfig {
+	if c.M <= 0 {
+		c.M = 16
+	}
+	if c.EfConstruction <= 0 {
+		c.EfConstruction = 200
+	}
+	if c.EfSearch <= 0 {
+		c.EfSearch = 64
+	}
+	return c
+}
+
+// hnswIndex 是分层可导航小世界图索引，按余弦相似度检索
+// 删除只标记节点，节点仍参与图的遍历但不出现在结果中，被标记的节点超过四分之一时重建索引
+// 索引本身不加锁，由 MemoryStore 的读写锁保护
+type hnswIndex struct {
+	config    HNSWConfig
+	levelMult float64
+	rng       *rand.Rand
+
+	nodes    []*hnswNode
+	ids      map[string]int32 // 记录 ID 到未删除节点的映射
+	deleted  int
+	entry    int32 // 入口节点，-1 表示索引为空
+	maxLevel int
+}
+
+type hnswNode struct {
+	id        string
+	vector    []float32
+	norm      float32
+	neighbors [][]int32 // 每层的邻居
+	deleted   bool
+}
+
+func newHNSWIndex(config HNSWConfig) *hnswIndex {
+	config = config.withDefaults()
+	return &hnswIndex{
+		config:    config,
+		levelMult: 1 / math.Log(float64(config.M)),
+		rng:       rand.New(rand.NewPCG(1, 2)),
+		ids:       make(map[string]int32),
+		entry:     -1,
+	}
+}
+
+// insert 插入向量，ID 已存在时替换原向量
+func (h *hnswIndex) insert(id string, vector []float32) {
+	h.remove(id)
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	node := &hnswNode{
+		id:        id,
+		vector:    vector,
+		norm:      norm(vector),
+		neighbors: make([][]int32, level+1),
+	}
+	n := int32(len(h.nodes))
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = n
+	if h.entry < 0 {
+		h.entry = n
+		h.maxLevel = level
+		return
+	}
+
+	ep := []candidate{{id: h.entry, sim: h.similarity(node, h.nodes[h.entry])}}
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.searchLayer(node.vector, node.norm, ep, 1, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(node.vector, node.norm, ep, h.config.EfConstruction, l)
+		node.neighbors[l] = h.selectNeighbors(node, found, h.config.M)
+		for _, nb := range node.neighbors[l] {
+			h.connect(h.nodes[nb], n, l)
+		}
+		ep = found
+	}
+	if level > h.maxLevel {
+		h.entry = n
+		h.maxLevel = level
+	}
+}
+
+// connect 为 node 在第 l 层加上邻居 n，超出上限时重新挑选邻居
+func (h *hnswIndex) connect(node *hnswNode, n int32, l int) {
+	node.neighbors[l] = append(node.neighbors[l], n)
+	limit := h.maxNeighbors(l)
+	if len(node.neighbors[l]) <= limit {
+		return
+	}
+	candidates := make([]candidate, 0, len(node.neighbors[l]))
+	for _, nb := range node.neighbors[l] {
+		candidates = append(candidates, candidate{id: nb, sim: h.similarity(node, h.nodes[nb])})
+	}
+	node.neighbors[l] = h.selectNeighbors(node, candidates, limit)
+}
+
+// remove 标记删除 ID 对应的节点，返回节点是否存在
+func (h *hnswIndex) remove(id string) bool {
+	n, ok := h.ids[id]
+	if !ok {
+		return false
+	}
+	h.nodes[n].deleted = true
+	delete(h.ids, id)
+	h.deleted++
+	if h.deleted > len(h.nodes)/4 {
+		h.rebuild()
+	}
+	return true
+}
+
+// rebuild 只用未删除的节点重建索引
+func (h *hnswIndex) rebuild() {
+	nodes := h.nodes
+	h.nodes = nil
+	h.ids = make(map[string]int32, len(nodes)-h.deleted)
+	h.deleted = 0
+	h.entry = -1
+	h.maxLevel = 0
+	for _, node := range nodes {
+		if !node.deleted {
+			h.insert(node.id, node.vector)
+		}
+	}
+}
+
+// search 返回与 vector 最相似的 k 个节点的 ID，按相似度从高到低排序
+func (h *hnswIndex) search(vector []float32, k int) []string {
+	if h.entry < 0 || k <= 0 {
+		return nil
+	}
+	vnorm := norm(vector)
+	ep := []candidate{{id: h.entry, sim: cosine(vector, vnorm, h.nodes[h.entry])}}
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.searchLayer(vector, vnorm, ep, 1, l)
+	}
+	found := h.searchLayer(vector, vnorm, ep, max(h.config.EfSearch, k), 0)
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].sim > found[j].sim
+	})
+	ids := make([]string, 0, k)
+	for _, c := range found {
+		if node := h.nodes[c.id]; !node.deleted {
+			ids = append(ids, node.id)
+			if len(ids) == k {
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// searchLayer 从 ep 出发在第 l 层贪心搜索，返回最多 ef 个最相似的节点
+func (h *hnswIndex) searchLayer(vector []float32, vnorm float32, ep []candidate, ef int, l int) []candidate {
+	visited := make([]uint64, (len(h.nodes)+63)/64)
+	candidates := &candidateHeap{max: true}
+	results := &candidateHeap{}
+	for _, c := range ep {
+		visited[c.id/64] |= 1 << (c.id % 64)
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.sim < results.items[0].sim {
+			break
+		}
+		for _, nb := range h.nodes[c.id].neighbors[l] {
+			if visited[nb/64]&(1<<(nb%64)) != 0 {
+				continue
+			}
+			visited[nb/64] |= 1 << (nb % 64)
+			sim := cosine(vector, vnorm, h.nodes[nb])
+			if results.Len() < ef || sim > results.items[0].sim {
+				heap.Push(candidates, candidate{id: nb, sim: sim})
+				heap.Push(results, candidate{id: nb, sim: sim})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	return results.items
+}
+
+// selectNeighbors 用启发式方法从 candidates 中挑选最多 m 个邻居：
+// 优先保留比已选邻居更接近 node 的候选，使邻居分布在不同方向上，不足 m 个时再用其余候选补齐
+func (h *hnswIndex) selectNeighbors(node *hnswNode, candidates []candidate, m int) []int32 {
+	sorted := make([]candidate, len(candidates))
+	copy(sorted, candidates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].sim > sorted[j].sim
+	})
+	selected := make([]int32, 0, m)
+	pruned := make([]int32, 0, len(sorted))
+	for _, c := range sorted {
+		if len(selected) == m {
+			break
+		}
+		if h.nodes[c.id] == node {
+			continue
+		}
+		keep := true
+		for _, s := range selected {
+			if h.similarity(h.nodes[c.id], h.nodes[s]) > c.sim {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for _, p := range pruned {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+func (h *hnswIndex) maxNeighbors(l int) int {
+	if l == 0 {
+		return 2 * h.config.M
+	}
+	return h.config.M
+}
+
+func (h *hnswIndex) similarity(a, b *hnswNode) float32 {
+	return cosine(a.vector, a.norm, b)
+}
+
+// cosine 计算向量与节点的余弦相似度，任一向量为零向量时返回 0
+func cosine(vector []float32, vnorm float32, node *hnswNode) float32 {
+	if vnorm == 0 || node.norm == 0 || len(vector) != len(node.vector) {
+		return 0
+	}
+	var dot float32
+	for i, x := range vector {
+		dot += x * node.vector[i]
+	}
+	return dot / (vnorm * node.norm)
+}
+
+func norm(vector []float32) float32 {
+	var sum float32
+	for _, x := range vector {
+		sum += x * x
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+type candidate struct {
+	id  int32
+	sim float32
+}
+
+// candidateHeap 按相似度排序的堆，max 为 true 时堆顶为最相似的候选，否则为最不相似的候选
+type candidateHeap struct {
+	items []candidate
+	max   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.items[i].sim > h.items[j].sim
+	}
+	return h.items[i].sim < h.items[j].sim
+}
+
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *candidateHeap) Push(x any) { h.items = append(h.items, x.(candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
@@ -1,11 +1,14 @@
 package vectorstore
 
 import (
+	"container/heap"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/hl540/rag/embedding"
 	"math"
 	"sort"
+	"sync"
 )
 
 // MemoryVectorRecord 表示一条向量记录，包含 ID、文本、向量嵌入和元数据
@@ -16,17 +19,143 @@ type MemoryVectorRecord struct {
 	Metadata  map[string]any
 }
 
-// MemoryStore 是一个内存向量存储，支持按名称分组存储向量记录
+// memoryCollection 是一个集合中的全部记录
+type memoryCollection struct {
+	records    []*MemoryVectorRecord
+	index      map[string]int // 记录 ID 到 records 下标的映射
+	model      string         // 生成向量的模型名称，为空表示未知
+	normalized bool           // 集合中的向量是否经过 L2 归一化
+	hnsw       *hnswIndex     // 近似最近邻索引，为 nil 时只使用暴力检索
+}
+
+func newMemoryCollection(model string, normalized bool) *memoryCollection {
+	return &memoryCollection{
+		index:      make(map[string]int),
+		model:      model,
+		normalized: normalized,
+	}
+}
+
+// checkModel 检查 model 与集合的模型是否一致，任一方未知时不检查
+func (c *memoryCollection) checkModel(model string) error {
+	if c.model != "" && model != "" && c.model != model {
+		return fmt.Errorf("%w: collection uses %s, embedder uses %s", ErrModelMismatch, c.model, model)
+	}
+	return nil
+}
+
+// dimension 返回集合中向量的维度，集合为空时返回 0
+func (c *memoryCollection) dimension() int {
+	if len(c.records) == 0 {
+		return 0
+	}
+	return len(c.records[0].Embedding)
+}
+
+// upsert 写入记录，ID 已存在时替换原记录
+func (c *memoryCollection) upsert(record *MemoryVectorRecord) {
+	if c.hnsw != nil {
+		c.hnsw.insert(record.Id, record.Embedding)
+	}
+	if i, ok := c.index[record.Id]; ok {
+		c.records[i] = record
+		return
+	}
+	c.index[record.Id] = len(c.records)
+	c.records = append(c.records, record)
+}
+
+// get 按 ID 查找记录
+func (c *memoryCollection) get(id string) (*MemoryVectorRecord, bool) {
+	i, ok := c.index[id]
+	if !ok {
+		return nil, false
+	}
+	return c.records[i], true
+}
+
+// delete 按 ID 删除记录，用最后一条记录填补空位
+func (c *memoryCollection) delete(id string) bool {
+	i, ok := c.index[id]
+	if !ok {
+		return false
+	}
+	if c.hnsw != nil {
+		c.hnsw.remove(id)
+	}
+	last := len(c.records) - 1
+	if i != last {
+		c.records[i] = c.records[last]
+		c.index[c.records[i].Id] = i
+	}
+	c.records[last] = nil
+	c.records = c.records[:last]
+	delete(c.index, id)
+	return true
+}
+
+// buildIndex 为集合中已有的记录建立 HNSW 索引
+func (c *memoryCollection) buildIndex(config HNSWConfig) {
+	c.hnsw = newHNSWIndex(config)
+	for _, record := range c.records {
+		c.hnsw.insert(record.Id, record.Embedding)
+	}
+}
+
+// MemoryStore 是一个内存向量存储，支持按名称分组存储向量记录，可以安全地并发使用
+// 生成向量嵌入不持有锁，因此写入文档时检索仍可以进行
 type MemoryStore struct {
 	embedder embedding.Embedder
-	store    map[string][]*MemoryVectorRecord
+	mu       sync.RWMutex
+	store    map[string]*memoryCollection
+	log      *appendLog  // 追加日志，为 nil 时不持久化
+	hnsw     *HNSWConfig // 为 nil 时不建立 HNSW 索引
+}
+
+type MemoryOption func(o *MemoryStore)
+
+// WithHNSW 为每个集合建立 HNSW 近似最近邻索引，没有过滤条件的检索使用索引，
+// 带过滤条件或使用 WithExactSearch 的检索仍然使用暴力检索
+func WithHNSW(config HNSWConfig) MemoryOption {
+	return func(o *MemoryStore) {
+		o.hnsw = &config
+	}
 }
 
 // NewMemoryStore 创建一个新的 VectorStore 实例
-func NewMemoryStore(embedder embedding.Embedder) VectorStore {
-	return &MemoryStore{
+func NewMemoryStore(embedder embedding.Embedder, opts ...MemoryOption) VectorStore {
+	return newMemoryStore(embedder, opts)
+}
+
+func newMemoryStore(embedder embedding.Embedder, opts []MemoryOption) *MemoryStore {
+	v := &MemoryStore{
 		embedder: embedder,
-		store:    make(map[string][]*MemoryVectorRecord),
+		store:    make(map[string]*memoryCollection),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// newCollection 创建一个空集合，开启 HNSW 时同时创建索引
+func (v *MemoryStore) newCollection(model string, normalized bool) *memoryCollection {
+	collection := newMemoryCollection(model, normalized)
+	if v.hnsw != nil {
+		collection.hnsw = newHNSWIndex(*v.hnsw)
+	}
+	return collection
+}
+
+// buildIndexes 为还没有索引的集合建立 HNSW 索引
+func (v *MemoryStore) buildIndexes(store map[string]*memoryCollection) {
+	if v.hnsw == nil {
+		return
+	}
+	for _, collection := range store {
+		if collection.hnsw == nil {
+			collection.buildIndex(*v.hnsw)
+		}
 	}
 }
 
@@ -35,48 +164,100 @@ func (v *MemoryStore) AddDocuments(ctx context.Context, name string, docs []*Doc
 	if len(docs) == 0 {
 		return errors.New("empty documents")
 	}
-	if v.store[name] == nil {
-		v.store[name] = make([]*MemoryVectorRecord, 0)
-	}
-
-	texts := make([]string, 0, len(docs))
 	for _, doc := range docs {
-		texts = append(texts, doc.Text)
+		if err := checkMetadata(doc.Metadata); err != nil {
+			return fmt.Errorf("document %s: %w", doc.Id, err)
+		}
 	}
-	embeds, err := v.embedder.Embeds(ctx, texts)
+	normalized := embedding.IsNormalized(v.embedder)
+	model := embedding.ModelName(v.embedder)
+
+	embeds, err := embedDocuments(ctx, v.embedder, docs)
 	if err != nil {
 		return err
 	}
 
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	collection := v.store[name]
+	if collection == nil {
+		collection = v.newCollection(model, normalized)
+	}
+	if collection.normalized != normalized {
+		return ErrNormalizationMismatch
+	}
+	if err := collection.checkModel(model); err != nil {
+		return err
+	}
+	if dimension := collection.dimension(); dimension > 0 {
+		if err := checkDimension(docs, embeds, dimension); err != nil {
+			return err
+		}
+	}
+	records := make([]*MemoryVectorRecord, 0, len(docs))
 	for i, doc := range docs {
-		embed := embeds[i]
-		v.store[name] = append(v.store[name], &MemoryVectorRecord{
+		records = append(records, &MemoryVectorRecord{
 			Id:        doc.Id,
 			Text:      doc.Text,
-			Embedding: embed,
+			Embedding: embeds[i],
 			Metadata:  doc.Metadata,
 		})
 	}
+	// 先写日志再修改内存，日志写入失败时内存中的数据保持不变
+	if err := v.log.appendUpsert(name, collection, records); err != nil {
+		return err
+	}
+	v.store[name] = collection
+	for _, record := range records {
+		collection.upsert(record)
+	}
 	return nil
 }
 
 // SimilaritySearch 在指定名称的存储中搜索与查询最相似的 topK 条记录
-func (v *MemoryStore) SimilaritySearch(ctx context.Context, name string, query string, topK int) ([]*SearchResult, error) {
+func (v *MemoryStore) SimilaritySearch(ctx context.Context, name string, query string, topK int, opts ...SearchOption) ([]*SearchResult, error) {
 	if len(query) == 0 {
 		return nil, errors.New("empty query")
 	}
+	if topK <= 0 {
+		return nil, errors.New("topK must be positive")
+	}
+	options, err := newSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	if v.store[name] == nil {
-		return nil, errors.New("no such document")
+	if !v.hasCollection(name) {
+		return nil, ErrCollectionNotFound
 	}
 
-	embed, err := v.embedder.Embed(ctx, query)
+	embed, err := embedding.EmbedQuery(ctx, v.embedder, query)
 	if err != nil {
 		return nil, err
 	}
 
-	similarities := make([]*SearchResult, 0)
-	for _, doc := range v.store[name] {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	collection := v.store[name]
+	if collection == nil {
+		return nil, ErrCollectionNotFound
+	}
+	if err := collection.checkModel(embedding.ModelName(v.embedder)); err != nil {
+		return nil, err
+	}
+	if collection.hnsw != nil && options.Filter == nil && !options.Exact {
+		return v.approximateSearch(collection, embed, topK)
+	}
+	return v.exactSearch(collection, embed, topK, options.Filter)
+}
+
+// exactSearch 计算查询与每条记录的相似度，返回最相似的 topK 条记录
+func (v *MemoryStore) exactSearch(collection *memoryCollection, embed []float32, topK int, filter *Filter) ([]*SearchResult, error) {
+	similarities := make([]*SearchResult, 0, len(collection.records))
+	for _, doc := range collection.records {
+		if filter != nil && !filter.Match(doc.Metadata) {
+			continue
+		}
 		similarity, err := v.CosineSimilarity(embed, doc.Embedding)
 		if err != nil {
 			return nil, err
@@ -84,6 +265,7 @@ func (v *MemoryStore) SimilaritySearch(ctx context.Context, name string, query s
 		similarities = append(similarities, &SearchResult{
 			ID:       doc.Id,
 			Score:    similarity,
+			Text:     doc.Text,
 			Metadata: doc.Metadata,
 		})
 	}
@@ -96,6 +278,161 @@ func (v *MemoryStore) SimilaritySearch(ctx context.Context, name string, query s
 	return similarities[:topK], nil
 }
 
+// approximateSearch 通过 HNSW 索引检索，得分与 exactSearch 的计算方式相同
+func (v *MemoryStore) approximateSearch(collection *memoryCollection, embed []float32, topK int) ([]*SearchResult, error) {
+	ids := collection.hnsw.search(embed, topK)
+	results := make([]*SearchResult, 0, len(ids))
+	for _, id := range ids {
+		doc, _ := collection.get(id)
+		similarity, err := v.CosineSimilarity(embed, doc.Embedding)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &SearchResult{
+			ID:       doc.Id,
+			Score:    similarity,
+			Text:     doc.Text,
+			Metadata: doc.Metadata,
+		})
+	}
+	return results, nil
+}
+
+// GetDocuments 按 ID 读取文档
+func (v *MemoryStore) GetDocuments(ctx context.Context, name string, ids []string) ([]*Document, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	collection := v.store[name]
+	if collection == nil {
+		return nil, ErrCollectionNotFound
+	}
+	docs := make([]*Document, 0, len(ids))
+	for _, id := range ids {
+		record, ok := collection.get(id)
+		if !ok {
+			continue
+		}
+		docs = append(docs, &Document{
+			Id:       record.Id,
+			Text:     record.Text,
+			Metadata: record.Metadata,
+		})
+	}
+	return docs, nil
+}
+
+// ScrollDocuments 按 ID 的字典序返回一页文档，翻页期间写入的文档只要 ID 在当前位置之后就会被读到
+// 返回的向量和元数据与存储共享，调用方不应修改
+func (v *MemoryStore) ScrollDocuments(ctx context.Context, name string, opts ScrollOptions) (*ScrollPage, error) {
+	if opts.Filter != nil {
+		if err := opts.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	collection := v.store[name]
+	if collection == nil {
+		return nil, ErrCollectionNotFound
+	}
+
+	// 用大小为 limit+1 的大顶堆选出 ID 最小的记录，多出的一条用于判断是否还有下一页
+	page := &recordHeap{}
+	for _, record := range collection.records {
+		if opts.Offset != "" && record.Id < opts.Offset {
+			continue
+		}
+		if opts.Filter != nil && !opts.Filter.Match(record.Metadata) {
+			continue
+		}
+		if page.Len() <= limit {
+			heap.Push(page, record)
+		} else if record.Id < (*page)[0].Id {
+			(*page)[0] = record
+			heap.Fix(page, 0)
+		}
+	}
+	records := []*MemoryVectorRecord(*page)
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Id < records[j].Id
+	})
+
+	result := &ScrollPage{Documents: make([]*Document, 0, min(len(records), limit))}
+	if len(records) > limit {
+		result.NextOffset = records[limit].Id
+		records = records[:limit]
+	}
+	for _, record := range records {
+		doc := &Document{
+			Id:       record.Id,
+			Text:     record.Text,
+			Metadata: record.Metadata,
+		}
+		if opts.WithEmbeddings {
+			doc.Embedding = record.Embedding
+		}
+		result.Documents = append(result.Documents, doc)
+	}
+	return result, nil
+}
+
+// DeleteDocuments 按 ID 删除文档
+func (v *MemoryStore) DeleteDocuments(ctx context.Context, name string, ids []string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	collection := v.store[name]
+	if collection == nil {
+		return ErrCollectionNotFound
+	}
+	if err := v.log.appendDelete(name, ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		collection.delete(id)
+	}
+	return nil
+}
+
+// DeleteByFilter 删除元数据满足 filter 的全部文档
+func (v *MemoryStore) DeleteByFilter(ctx context.Context, name string, filter *Filter) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	collection := v.store[name]
+	if collection == nil {
+		return ErrCollectionNotFound
+	}
+	ids := make([]string, 0)
+	for _, record := range collection.records {
+		if filter.Match(record.Metadata) {
+			ids = append(ids, record.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := v.log.appendDelete(name, ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		collection.delete(id)
+	}
+	return nil
+}
+
+// hasCollection 判断集合是否存在
+func (v *MemoryStore) hasCollection(name string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.store[name] != nil
+}
+
 // CosineSimilarity 计算两个向量的余弦相似度
 func (v *MemoryStore) CosineSimilarity(vec1, vec2 []float32) (float32, error) {
 	if len(vec1) != len(vec2) {
@@ -123,3 +460,21 @@ func (v *MemoryStore) CosineSimilarity(vec1, vec2 []float32) (float32, error) {
 
 	return float32(dotProduct / (magnitude1 * magnitude2)), nil
 }
+
+// recordHeap 是按 ID 排序的大顶堆
+type recordHeap []*MemoryVectorRecord
+
+func (h recordHeap) Len() int { return len(h) }
+
+func (h recordHeap) Less(i, j int) bool { return h[i].Id > h[j].Id }
+
+func (h recordHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *recordHeap) Push(x any) { *h = append(*h, x.(*MemoryVectorRecord)) }
+
+func (h *recordHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
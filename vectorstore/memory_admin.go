@@ -0,0 +1,110 @@
+package vectorstore
+
+import (
+	"context"
+	"sort"
+)
+
+// ListCollections 返回全部集合的名称，按名称排序
+func (v *MemoryStore) ListCollections(ctx context.Context) ([]string, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	names := make([]string, 0, len(v.store))
+	for name := range v.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CollectionExists 判断集合是否存在
+func (v *MemoryStore) CollectionExists(ctx context.Context, name string) (bool, error) {
+	return v.hasCollection(name), nil
+}
+
+// CollectionInfo 返回集合的信息，MemoryStore 总是使用余弦相似度
+func (v *MemoryStore) CollectionInfo(ctx context.Context, name string) (*CollectionInfo, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	collection := v.store[name]
+	if collection == nil {
+		return nil, ErrCollectionNotFound
+	}
+	info := &CollectionInfo{
+		Name:       name,
+		Count:      len(collection.records),
+		Distance:   DistanceCosine,
+		Model:      collection.model,
+		Normalized: collection.normalized,
+		Dimension:  collection.dimension(),
+	}
+	return info, nil
+}
+
+// Count 返回元数据满足 filter 的文档数量
+func (v *MemoryStore) Count(ctx context.Context, name string, filter *Filter) (int, error) {
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return 0, err
+		}
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	collection := v.store[name]
+	if collection == nil {
+		return 0, ErrCollectionNotFound
+	}
+	if filter == nil {
+		return len(collection.records), nil
+	}
+	count := 0
+	for _, record := range collection.records {
+		if filter.Match(record.Metadata) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DropCollection 删除集合及其全部文档
+func (v *MemoryStore) DropCollection(ctx context.Context, name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.store[name] == nil {
+		return ErrCollectionNotFound
+	}
+	if err := v.log.appendDrop(name); err != nil {
+		return err
+	}
+	delete(v.store, name)
+	return nil
+}
+
+// RenameCollection 重命名集合
+func (v *MemoryStore) RenameCollection(ctx context.Context, name, newName string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	collection := v.store[name]
+	if collection == nil {
+		return ErrCollectionNotFound
+	}
+	if v.store[newName] != nil {
+		return ErrCollectionExists
+	}
+	if err := v.log.appendRename(name, newName); err != nil {
+		return err
+	}
+	delete(v.store, name)
+	v.store[newName] = collection
+	return nil
+}
+
+// CreateAlias MemoryStore 不支持别名
+func (v *MemoryStore) CreateAlias(ctx context.Context, alias, name string) error {
+	return ErrNotSupported
+}
+
+// DeleteAlias MemoryStore 不支持别名
+func (v *MemoryStore) DeleteAlias(ctx context.Context, alias string) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,640 @@
+package vectorstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// 快照文件格式（整数均为小端序）：
+//
+//	magic "VSM" + 版本号 1 字节
+//	uint64 代数
+//	uint32 集合数量
+//	  string 集合名称, string 模型名称, uint8 是否归一化, uint32 记录数量
+//	    record...
+//	uint32 之前全部内容的 CRC32
+//
+// record 为 string ID, string 文本, uint32 维度, 维度个 float32, bytes 元数据 JSON（长度为 0 表示 nil）
+// string 和 bytes 均为 uint32 长度加内容
+//
+// 追加日志格式：
+//
+//	magic "VSL" + 版本号 1 字节
+//	uint64 代数
+//	entry...
+//
+// 每次 Compact 代数加一，日志只记录快照之后的操作，因此只重放与快照代数相同的日志。
+// Compact 在新快照写入后、日志清空前崩溃时，残留日志的代数小于快照，打开时会被丢弃
+//
+// entry 为 uint32 长度, uint32 内容的 CRC32, 内容。内容以 1 字节操作类型开头：
+// 写入为 string 集合名称, string 模型名称, uint8 是否归一化, uint32 记录数量, record...；
+// 删除为 string 集合名称, uint32 ID 数量, string ID...；
+// 删除集合为 string 集合名称；重命名集合为 string 原名称, string 新名称
+var (
+	snapshotMagic = [4]byte{'V', 'S', 'M', 2}
+	logMagic      = [4]byte{'V', 'S', 'L', 2}
+)
+
+const (
+	snapshotFile = "snapshot.bin"
+	logFile      = "append.log"
+
+	logOpUpsert byte = 1
+	logOpDelete byte = 2
+	logOpDrop   byte = 3
+	logOpRename byte = 4
+
+	// maxLogEntry 是单条日志的最大长度，超过时视为损坏，避免按损坏的长度分配内存
+	maxLogEntry = 1 << 30
+)
+
+// ErrCorruptSnapshot 表示快照文件已损坏或格式不受支持
+var ErrCorruptSnapshot = errors.New("vectorstore: corrupt snapshot")
+
+// OpenMemoryStore 打开 dir 下持久化的内存向量存储，dir 不存在时会被创建
+// 打开时先读取快照，再重放追加日志；之后每次写入和删除都会先追加到日志并同步到磁盘，
+// 进程崩溃最多丢失最后一条未写完的日志，重放时会被截断。调用 Compact 将日志合并进快照
+func OpenMemoryStore(embedder embedding.Embedder, dir string, opts ...MemoryOption) (*MemoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	v := newMemoryStore(embedder, opts)
+	store, generation, err := loadSnapshot(filepath.Join(dir, snapshotFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if store != nil {
+		v.store = store
+	}
+	log, err := openAppendLog(filepath.Join(dir, logFile), v.store, generation)
+	if err != nil {
+		return nil, err
+	}
+	v.log = log
+	v.buildIndexes(v.store)
+	return v, nil
+}
+
+// Save 将全部集合写入 path 处的快照文件
+// 先写入同目录下的临时文件并同步到磁盘，再重命名为 path，因此不会留下不完整的快照
+func (v *MemoryStore) Save(path string) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.save(path, v.log.generation())
+}
+
+func (v *MemoryStore) save(path string, generation uint64) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	crc := crc32.NewIEEE()
+	w := bufio.NewWriter(tmp)
+	enc := &encoder{w: io.MultiWriter(w, crc)}
+	enc.raw(snapshotMagic[:])
+	enc.uint64(generation)
+	enc.uint32(uint32(len(v.store)))
+	for name, collection := range v.store {
+		enc.collectionHeader(name, collection.model, collection.normalized)
+		enc.uint32(uint32(len(collection.records)))
+		for _, record := range collection.records {
+			enc.record(record)
+		}
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	if err = binary.Write(w, binary.LittleEndian, crc.Sum32()); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
+	return nil
+}
+
+// Load 从 path 处的快照文件读取全部集合，替换当前内容
+// 元数据以 JSON 保存，读取后数字统一为 float64
+// 对 OpenMemoryStore 打开的存储，读取的内容会像 Compact 一样写入下一代快照并清空追加日志，
+// 保证重新打开后与当前内容一致；写入快照失败时保留原内容
+func (v *MemoryStore) Load(path string) error {
+	store, _, err := loadSnapshot(path)
+	if err != nil {
+		return err
+	}
+	v.buildIndexes(store)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	previous := v.store
+	v.store = store
+	if v.log == nil {
+		return nil
+	}
+	generation := v.log.gen + 1
+	if err := v.save(v.snapshotPath(), generation); err != nil {
+		v.store = previous
+		return err
+	}
+	return v.log.reset(generation)
+}
+
+// Compact 将当前内容写入下一代快照并清空追加日志，只能用于 OpenMemoryStore 打开的存储
+// 快照重命名成功后进程崩溃时日志尚未清空，残留日志属于上一代，下次打开时被丢弃而不会重放
+func (v *MemoryStore) Compact() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.log == nil {
+		return errors.New("vectorstore: store is not persistent")
+	}
+	generation := v.log.gen + 1
+	if err := v.save(v.snapshotPath(), generation); err != nil {
+		return err
+	}
+	return v.log.reset(generation)
+}
+
+// snapshotPath 返回追加日志所在目录的快照路径，只能用于 OpenMemoryStore 打开的存储
+func (v *MemoryStore) snapshotPath() string {
+	return filepath.Join(filepath.Dir(v.log.file.Name()), snapshotFile)
+}
+
+// Close 关闭追加日志，之后的写入会返回错误
+func (v *MemoryStore) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.log == nil {
+		return nil
+	}
+	return v.log.file.Close()
+}
+
+// loadSnapshot 读取 path 处的快照，返回全部集合和快照的代数
+func loadSnapshot(path string) (map[string]*memoryCollection, uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	store, generation, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s: %v", ErrCorruptSnapshot, path, err)
+	}
+	return store, generation, nil
+}
+
+func decodeSnapshot(data []byte) (map[string]*memoryCollection, uint64, error) {
+	if len(data) < len(snapshotMagic)+4 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	body, sum := data[:len(data)-4], binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	if !bytes.Equal(body[:len(snapshotMagic)], snapshotMagic[:]) {
+		return nil, 0, fmt.Errorf("unsupported format %q", body[:len(snapshotMagic)])
+	}
+	dec := &decoder{r: bytes.NewReader(body[len(snapshotMagic):])}
+	generation := dec.uint64()
+	store := make(map[string]*memoryCollection)
+	for n := dec.uint32(); n > 0 && dec.err == nil; n-- {
+		name, collection := dec.collectionHeader()
+		for count := dec.uint32(); count > 0 && dec.err == nil; count-- {
+			collection.upsert(dec.record())
+		}
+		store[name] = collection
+	}
+	return store, generation, dec.err
+}
+
+// appendLog 是 MemoryStore 的追加日志，所有方法在 MemoryStore 的写锁下调用
+// 方法允许 nil 接收者，此时不做任何事
+type appendLog struct {
+	file *os.File
+	gen  uint64
+	err  error // 清空或回滚日志失败后日志的状态未知，之后的写入都返回该错误
+}
+
+// openAppendLog 打开 path 处的日志并重放到 store，generation 为快照的代数
+// 属于更早代数的日志已经包含在快照中，会被清空；末尾不完整或校验失败的条目会被截断
+func openAppendLog(path string, store map[string]*memoryCollection, generation uint64) (*appendLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := replayLog(file, store, generation)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	log := &appendLog{file: file, gen: generation}
+	if offset == 0 {
+		if err := log.writeHeader(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// replayLog 重放代数为 generation 的日志，返回最后一条完整条目之后的偏移量
+// 日志属于更早的代数时不重放并返回 0，属于更晚的代数时说明快照丢失或被替换，返回错误
+func replayLog(r io.Reader, store map[string]*memoryCollection, generation uint64) (int64, error) {
+	br := bufio.NewReader(r)
+	var magic [4]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		// 空文件或头部未写完
+		return 0, nil
+	}
+	if magic != logMagic {
+		return 0, fmt.Errorf("vectorstore: unsupported append log format %q", magic[:])
+	}
+	var gen [8]byte
+	if _, err := io.ReadFull(br, gen[:]); err != nil {
+		return 0, nil
+	}
+	switch logGeneration := binary.LittleEndian.Uint64(gen[:]); {
+	case logGeneration < generation:
+		return 0, nil
+	case logGeneration > generation:
+		return 0, fmt.Errorf("vectorstore: append log generation %d is newer than snapshot generation %d", logGeneration, generation)
+	}
+	offset := int64(len(logMagic) + len(gen))
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return offset, nil
+		}
+		size := binary.LittleEndian.Uint32(header[:4])
+		if size > maxLogEntry {
+			return offset, nil
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return offset, nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:]) {
+			return offset, nil
+		}
+		if err := applyLogEntry(payload, store); err != nil {
+			return 0, fmt.Errorf("vectorstore: append log at offset %d: %w", offset, err)
+		}
+		offset += int64(len(header)) + int64(size)
+	}
+}
+
+func applyLogEntry(payload []byte, store map[string]*memoryCollection) error {
+	if len(payload) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	dec := &decoder{r: bytes.NewReader(payload[1:])}
+	switch payload[0] {
+	case logOpUpsert:
+		name, header := dec.collectionHeader()
+		if dec.err != nil {
+			return dec.err
+		}
+		collection := store[name]
+		if collection == nil {
+			collection = header
+			store[name] = collection
+		}
+		for n := dec.uint32(); n > 0 && dec.err == nil; n-- {
+			collection.upsert(dec.record())
+		}
+	case logOpDelete:
+		name := dec.string()
+		collection := store[name]
+		for n := dec.uint32(); n > 0 && dec.err == nil; n-- {
+			id := dec.string()
+			if collection != nil {
+				collection.delete(id)
+			}
+		}
+	case logOpDrop:
+		delete(store, dec.string())
+	case logOpRename:
+		name, newName := dec.string(), dec.string()
+		if collection, ok := store[name]; ok {
+			delete(store, name)
+			store[newName] = collection
+		}
+	default:
+		return fmt.Errorf("unknown op %d", payload[0])
+	}
+	return dec.err
+}
+
+func (l *appendLog) writeHeader() error {
+	header := make([]byte, 0, len(logMagic)+8)
+	header = append(header, logMagic[:]...)
+	header = binary.LittleEndian.AppendUint64(header, l.gen)
+	if _, err := l.file.Write(header); err != nil {
+		return err
+	}
+	return l.file.Sync()
+}
+
+// generation 返回日志的代数，nil 接收者返回 0
+func (l *appendLog) generation() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.gen
+}
+
+// appendUpsert 记录写入操作，collection 提供集合的模型名称和归一化状态
+func (l *appendLog) appendUpsert(name string, collection *memoryCollection, records []*MemoryVectorRecord) error {
+	if l == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := &encoder{w: &buf}
+	enc.raw([]byte{logOpUpsert})
+	enc.collectionHeader(name, collection.model, collection.normalized)
+	enc.uint32(uint32(len(records)))
+	for _, record := range records {
+		enc.record(record)
+	}
+	if enc.err != nil {
+		return enc.err
+	}
+	return l.append(buf.Bytes())
+}
+
+// appendDelete 记录删除操作
+func (l *appendLog) appendDelete(name string, ids []string) error {
+	if l == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := &encoder{w: &buf}
+	enc.raw([]byte{logOpDelete})
+	enc.string(name)
+	enc.uint32(uint32(len(ids)))
+	for _, id := range ids {
+		enc.string(id)
+	}
+	return l.append(buf.Bytes())
+}
+
+// appendDrop 记录删除集合操作
+func (l *appendLog) appendDrop(name string) error {
+	if l == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := &encoder{w: &buf}
+	enc.raw([]byte{logOpDrop})
+	enc.string(name)
+	return l.append(buf.Bytes())
+}
+
+// appendRename 记录重命名集合操作
+func (l *appendLog) appendRename(name, newName string) error {
+	if l == nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := &encoder{w: &buf}
+	enc.raw([]byte{logOpRename})
+	enc.string(name)
+	enc.string(newName)
+	return l.append(buf.Bytes())
+}
+
+// append 写入一条日志并同步到磁盘
+// 写入或同步失败时截断回写入前的位置，避免调用方收到错误的操作在下次打开时被重放；
+// 截断也失败时日志状态未知，之后的写入都返回错误
+func (l *appendLog) append(payload []byte) error {
+	if l.err != nil {
+		return l.err
+	}
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	entry := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(entry[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(entry[4:], crc32.ChecksumIEEE(payload))
+	entry = append(entry, payload...)
+	_, err = l.file.Write(entry)
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.rollback(offset)
+		return err
+	}
+	return nil
+}
+
+// rollback 将日志截断到 offset，失败时记录到 l.err
+func (l *appendLog) rollback(offset int64) {
+	if err := l.file.Truncate(offset); err != nil {
+		l.err = fmt.Errorf("vectorstore: roll back append log: %w", err)
+		return
+	}
+	if _, err := l.file.Seek(offset, io.SeekStart); err != nil {
+		l.err = fmt.Errorf("vectorstore: roll back append log: %w", err)
+	}
+}
+
+// reset 清空日志并开始代数为 generation 的新日志
+// 失败时新快照已经生效而日志仍属于上一代，继续追加的条目在下次打开时会被丢弃，因此之后的写入都返回错误
+func (l *appendLog) reset(generation uint64) error {
+	l.gen = generation
+	if err := l.file.Truncate(0); err != nil {
+		l.err = fmt.Errorf("vectorstore: reset append log: %w", err)
+		return l.err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		l.err = fmt.Errorf("vectorstore: reset append log: %w", err)
+		return l.err
+	}
+	if err := l.writeHeader(); err != nil {
+		l.err = fmt.Errorf("vectorstore: reset append log: %w", err)
+		return l.err
+	}
+	return nil
+}
+
+// syncDir 将目录项同步到磁盘，使重命名在掉电后仍然有效，失败时忽略
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// encoder 按快照格式写入数据，出错后忽略后续写入并保留第一个错误
+type encoder struct {
+	w   io.Writer
+	err error
+}
+
+func (e *encoder) raw(b []byte) {
+	if e.err == nil {
+		_, e.err = e.w.Write(b)
+	}
+}
+
+func (e *encoder) uint64(n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	e.raw(b[:])
+}
+
+func (e *encoder) uint32(n uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], n)
+	e.raw(b[:])
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uint32(uint32(len(b)))
+	e.raw(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) collectionHeader(name, model string, normalized bool) {
+	e.string(name)
+	e.string(model)
+	if normalized {
+		e.raw([]byte{1})
+	} else {
+		e.raw([]byte{0})
+	}
+}
+
+func (e *encoder) record(record *MemoryVectorRecord) {
+	e.string(record.Id)
+	e.string(record.Text)
+	e.uint32(uint32(len(record.Embedding)))
+	b := make([]byte, 4*len(record.Embedding))
+	for i, f := range record.Embedding {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	e.raw(b)
+	var metadata []byte
+	if record.Metadata != nil {
+		if metadata, e.err = json.Marshal(record.Metadata); e.err != nil {
+			return
+		}
+	}
+	e.bytes(metadata)
+}
+
+// decoder 按快照格式读取数据，出错后返回零值并保留第一个错误
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *decoder) raw(n uint32) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if int64(n) > int64(d.r.Len()) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	_, d.err = io.ReadFull(d.r, b)
+	return b
+}
+
+func (d *decoder) uint64() uint64 {
+	b := d.raw(8)
+	if d.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (d *decoder) uint32() uint32 {
+	b := d.raw(4)
+	if d.err != nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (d *decoder) bytes() []byte {
+	return d.raw(d.uint32())
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) collectionHeader() (string, *memoryCollection) {
+	name := d.string()
+	model := d.string()
+	normalized := d.raw(1)
+	if d.err != nil {
+		return "", nil
+	}
+	return name, newMemoryCollection(model, normalized[0] == 1)
+}
+
+func (d *decoder) record() *MemoryVectorRecord {
+	record := &MemoryVectorRecord{
+		Id:   d.string(),
+		Text: d.string(),
+	}
+	dimension := d.uint32()
+	if uint64(dimension)*4 > uint64(math.MaxUint32) {
+		d.err = errors.New("dimension too large")
+		return record
+	}
+	b := d.raw(dimension * 4)
+	if d.err != nil {
+		return record
+	}
+	record.Embedding = make([]float32, dimension)
+	for i := range record.Embedding {
+		record.Embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	if metadata := d.bytes(); len(metadata) > 0 && d.err == nil {
+		d.err = json.Unmarshal(metadata, &record.Metadata)
+	}
+	return record
+}
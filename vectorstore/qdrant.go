@@ -3,8 +3,11 @@ package vectorstore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/hl540/rag/embedding"
 	"github.com/qdrant/go-client/qdrant"
+	"math"
+	"sync"
 )
 
 // QdrantStore 是一个基于 Qdrant 的向量存储，支持文档的添加和相似度搜索
@@ -12,6 +15,8 @@ type QdrantStore struct {
 	client   *qdrant.Client
 	config   *qdrant.Config
 	embedder embedding.Embedder
+	schema   qdrantSchema
+	opened   sync.Map // 已经检查过配置的集合名称到向量维度的映射
 }
 
 // NewQdrantStore 创建一个新的 QdrantVectorStore 实例
@@ -20,6 +25,9 @@ func NewQdrantStore(opts ...QdrantOption) (VectorStore, error) {
 	for _, opt := range opts {
 		opt(store)
 	}
+	if err := store.schema.validate(); err != nil {
+		return nil, err
+	}
 	var err error
 	store.client, err = qdrant.NewClient(store.config)
 	if err != nil {
@@ -28,38 +36,40 @@ func NewQdrantStore(opts ...QdrantOption) (VectorStore, error) {
 	return store, nil
 }
 
-// createCollection 检查并创建 Qdrant 集合
-func (v *QdrantStore) createCollection(ctx context.Context, name string) error {
-	exists, err := v.client.CollectionExists(ctx, name)
+// checkEmbedder 检查集合中已有向量的模型和归一化状态是否与当前的向量嵌入器一致，
+// 任一方没有记录模型名称时不检查模型，normalized 为 nil 时不检查归一化状态，
+// 没有记录归一化状态的旧数据视为未归一化
+func (v *QdrantStore) checkEmbedder(ctx context.Context, name string, model string, normalized *bool) error {
+	limit := uint32(1)
+	points, err := v.client.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: name,
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayloadInclude(NormalizedKey, ModelKey),
+	})
 	if err != nil {
 		return err
 	}
-	if exists {
+	if len(points) == 0 {
 		return nil
 	}
-
-	embed, err := v.embedder.Embed(ctx, "test")
-	if err != nil {
-		return err
+	payload := points[0].Payload
+	if stored := payload[ModelKey].GetStringValue(); stored != "" && model != "" && stored != model {
+		return fmt.Errorf("%w: collection uses %s, embedder uses %s", ErrModelMismatch, stored, model)
 	}
-	return v.client.CreateCollection(ctx, &qdrant.CreateCollection{
-		CollectionName: name,
-		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-			Size:     uint64(len(embed)),
-			Distance: qdrant.Distance_Dot,
-		}),
-	})
+	if normalized != nil && payload[NormalizedKey].GetBoolValue() != *normalized {
+		return ErrNormalizationMismatch
+	}
+	return nil
 }
 
-// AddDocuments 将文档添加到 Qdrant 集合中，并生成向量嵌入
+// AddDocuments 将文档添加到 Qdrant 集合中，并生成向量嵌入，ID 已存在的点会被覆盖
 func (v *QdrantStore) AddDocuments(ctx context.Context, name string, docs []*Document) error {
 	if len(docs) == 0 {
 		return errors.New("empty documents")
 	}
 
-	if err := v.createCollection(ctx, name); err != nil {
-		return err
-	}
+	normalized := embedding.IsNormalized(v.embedder)
+	model := embedding.ModelName(v.embedder)
 
 	// 设置批处理大小
 	batchSize := 100
@@ -74,25 +84,40 @@ func (v *QdrantStore) AddDocuments(ctx context.Context, name string, docs []*Doc
 
 		// 获取当前批次的文档
 		batchDocs := docs[i:end]
-		texts := make([]string, 0, len(batchDocs))
-		for _, doc := range batchDocs {
-			texts = append(texts, doc.Text)
-		}
 
 		// 生成当前批次的向量嵌入
-		embeds, err := v.embedder.Embeds(ctx, texts)
+		embeds, err := embedDocuments(ctx, v.embedder, batchDocs)
 		if err != nil {
 			return err
 		}
+		// 集合在第一批向量生成后按其维度创建，不需要额外生成一次向量；
+		// 之后每批都检查维度，文档自带的向量可能与集合不一致
+		if err := v.openCollection(ctx, name, len(embeds[0]), true); err != nil {
+			return err
+		}
+		if i == 0 {
+			if err := v.checkEmbedder(ctx, name, model, &normalized); err != nil {
+				return err
+			}
+		}
 
 		// 构建当前批次的点
 		points := make([]*qdrant.PointStruct, 0, len(embeds))
 		for j, doc := range batchDocs {
 			embed := embeds[j]
+			payload, err := toPayload(doc.Metadata)
+			if err != nil {
+				return fmt.Errorf("document %s: %w", doc.Id, err)
+			}
+			payload[NormalizedKey] = qdrant.NewValueBool(normalized)
+			payload[TextKey] = qdrant.NewValueString(doc.Text)
+			if model != "" {
+				payload[ModelKey] = qdrant.NewValueString(model)
+			}
 			points = append(points, &qdrant.PointStruct{
 				Id:      qdrant.NewID(doc.Id),
 				Vectors: qdrant.NewVectors(embed...),
-				Payload: qdrant.NewValueMap(doc.Metadata),
+				Payload: payload,
 			})
 		}
 
@@ -111,20 +136,41 @@ func (v *QdrantStore) AddDocuments(ctx context.Context, name string, docs []*Doc
 }
 
 // SimilaritySearch 在 Qdrant 集合中搜索与查询最相似的 topK 条记录
-func (v *QdrantStore) SimilaritySearch(ctx context.Context, name string, query string, topK int) ([]*SearchResult, error) {
+func (v *QdrantStore) SimilaritySearch(ctx context.Context, name string, query string, topK int, opts ...SearchOption) ([]*SearchResult, error) {
 	if len(query) == 0 {
 		return nil, errors.New("empty query")
 	}
+	if topK <= 0 {
+		return nil, errors.New("topK must be positive")
+	}
+	options, err := newSearchOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var filter *qdrant.Filter
+	if options.Filter != nil {
+		if filter, err = toQdrantFilter(options.Filter); err != nil {
+			return nil, err
+		}
+	}
 
-	embed, err := v.embedder.Embed(ctx, query)
+	embed, err := embedding.EmbedQuery(ctx, v.embedder, query)
 	if err != nil {
 		return nil, err
 	}
+	if err := v.openCollection(ctx, name, len(embed), false); err != nil {
+		return nil, err
+	}
+	if err := v.checkEmbedder(ctx, name, embedding.ModelName(v.embedder), nil); err != nil {
+		return nil, err
+	}
 
 	limit := uint64(topK)
 	searchResult, err := v.client.Query(ctx, &qdrant.QueryPoints{
 		CollectionName: name,
 		Query:          qdrant.NewQuery(embed...),
+		Filter:         filter,
+		Params:         v.searchParams(),
 		WithPayload:    qdrant.NewWithPayload(true),
 		Limit:          &limit,
 	})
@@ -133,15 +179,172 @@ func (v *QdrantStore) SimilaritySearch(ctx context.Context, name string, query s
 	}
 	docs := make([]*SearchResult, 0, len(searchResult))
 	for _, point := range searchResult {
+		text, metadata := fromPayload(point.Payload)
 		doc := &SearchResult{
 			ID:       point.Id.GetUuid(),
 			Score:    point.Score,
-			Metadata: make(map[string]any),
-		}
-		for key, value := range point.Payload {
-			doc.Metadata[key] = value.GetStringValue()
+			Text:     text,
+			Metadata: metadata,
 		}
 		docs = append(docs, doc)
 	}
 	return docs, nil
 }
+
+// GetDocuments 按 ID 读取文档
+func (v *QdrantStore) GetDocuments(ctx context.Context, name string, ids []string) ([]*Document, error) {
+	if err := v.checkCollection(ctx, name); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []*Document{}, nil
+	}
+	points, err := v.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: name,
+		Ids:            toPointIDs(ids),
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]*Document, len(points))
+	for _, point := range points {
+		text, metadata := fromPayload(point.Payload)
+		found[point.Id.GetUuid()] = &Document{
+			Id:       point.Id.GetUuid(),
+			Text:     text,
+			Metadata: metadata,
+		}
+	}
+	docs := make([]*Document, 0, len(points))
+	for _, id := range ids {
+		if doc, ok := found[id]; ok {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// ScrollDocuments 按点 ID 的顺序返回一页文档
+func (v *QdrantStore) ScrollDocuments(ctx context.Context, name string, opts ScrollOptions) (*ScrollPage, error) {
+	request := &qdrant.ScrollPoints{
+		CollectionName: name,
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(opts.WithEmbeddings),
+	}
+	if opts.Filter != nil {
+		filter, err := toQdrantFilter(opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		request.Filter = filter
+	}
+	if err := v.checkCollection(ctx, name); err != nil {
+		return nil, err
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	request.Limit = qdrant.PtrOf(uint32(min(limit, math.MaxUint32)))
+	if opts.Offset != "" {
+		request.Offset = qdrant.NewID(opts.Offset)
+	}
+	resp, err := v.client.GetPointsClient().Scroll(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ScrollPage{
+		Documents:  make([]*Document, 0, len(resp.GetResult())),
+		NextOffset: resp.GetNextPageOffset().GetUuid(),
+	}
+	for _, point := range resp.GetResult() {
+		text, metadata := fromPayload(point.Payload)
+		doc := &Document{
+			Id:       point.Id.GetUuid(),
+			Text:     text,
+			Metadata: metadata,
+		}
+		if opts.WithEmbeddings {
+			vector := point.GetVectors().GetVector()
+			doc.Embedding = vector.GetDense().GetData()
+			if doc.Embedding == nil {
+				doc.Embedding = vector.GetData()
+			}
+		}
+		page.Documents = append(page.Documents, doc)
+	}
+	return page, nil
+}
+
+// DeleteDocuments 按 ID 删除文档
+func (v *QdrantStore) DeleteDocuments(ctx context.Context, name string, ids []string) error {
+	if err := v.checkCollection(ctx, name); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return v.delete(ctx, name, qdrant.NewPointsSelectorIDs(toPointIDs(ids)))
+}
+
+// DeleteByFilter 删除元数据满足 filter 的全部文档
+func (v *QdrantStore) DeleteByFilter(ctx context.Context, name string, filter *Filter) error {
+	qdrantFilter, err := toQdrantFilter(filter)
+	if err != nil {
+		return err
+	}
+	if err := v.checkCollection(ctx, name); err != nil {
+		return err
+	}
+	return v.delete(ctx, name, qdrant.NewPointsSelectorFilter(qdrantFilter))
+}
+
+func (v *QdrantStore) delete(ctx context.Context, name string, selector *qdrant.PointsSelector) error {
+	wait := true
+	_, err := v.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: name,
+		Wait:           &wait,
+		Points:         selector,
+	})
+	return err
+}
+
+// checkCollection 检查集合是否存在，不存在时返回 ErrCollectionNotFound
+func (v *QdrantStore) checkCollection(ctx context.Context, name string) error {
+	exists, err := v.client.CollectionExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+// toPointIDs 将文档 ID 转换为 Qdrant 的点 ID
+func toPointIDs(ids []string) []*qdrant.PointId {
+	pointIDs := make([]*qdrant.PointId, 0, len(ids))
+	for _, id := range ids {
+		pointIDs = append(pointIDs, qdrant.NewID(id))
+	}
+	return pointIDs
+}
+
+// fromPayload 将 Qdrant payload 转换为文本和元数据，去掉保留字段
+// 没有 TextKey 的旧数据使用元数据中 ContentKey 的值作为文本
+func fromPayload(payload map[string]*qdrant.Value) (string, map[string]any) {
+	metadata := make(map[string]any, len(payload))
+	for key, value := range payload {
+		if key == NormalizedKey || key == TextKey || key == ModelKey {
+			continue
+		}
+		metadata[key] = FromQdrantValue(value)
+	}
+	if value, ok := payload[TextKey]; ok {
+		return value.GetStringValue(), metadata
+	}
+	text, _ := metadata[ContentKey].(string)
+	return text, metadata
+}
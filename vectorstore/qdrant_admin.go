@@ -0,0 +1,124 @@
+package vectorstore
+
+import (
+	"context"
+	"github.com/qdrant/go-client/qdrant"
+	"sort"
+)
+
+// ListCollections 返回全部集合的名称，按名称排序
+func (v *QdrantStore) ListCollections(ctx context.Context) ([]string, error) {
+	names, err := v.client.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CollectionExists 判断集合或别名是否存在
+func (v *QdrantStore) CollectionExists(ctx context.Context, name string) (bool, error) {
+	return v.client.CollectionExists(ctx, name)
+}
+
+// CollectionInfo 返回集合的信息，模型名称和归一化状态从任意一个点的保留 payload 字段读取
+func (v *QdrantStore) CollectionInfo(ctx context.Context, name string) (*CollectionInfo, error) {
+	if err := v.checkCollection(ctx, name); err != nil {
+		return nil, err
+	}
+	info, err := v.client.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	result := &CollectionInfo{
+		Name:      name,
+		Count:     int(info.GetPointsCount()),
+		Dimension: int(params.GetSize()),
+	}
+	for distance, qdrantDistance := range qdrantDistances {
+		if params.GetDistance() == qdrantDistance {
+			result.Distance = distance
+		}
+	}
+
+	limit := uint32(1)
+	points, err := v.client.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: name,
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayloadInclude(NormalizedKey, ModelKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(points) > 0 {
+		result.Normalized = points[0].Payload[NormalizedKey].GetBoolValue()
+		result.Model = points[0].Payload[ModelKey].GetStringValue()
+	}
+
+	if result.Aliases, err = v.client.ListCollectionAliases(ctx, name); err != nil {
+		return nil, err
+	}
+	sort.Strings(result.Aliases)
+	return result, nil
+}
+
+// Count 返回元数据满足 filter 的文档的精确数量
+func (v *QdrantStore) Count(ctx context.Context, name string, filter *Filter) (int, error) {
+	var qdrantFilter *qdrant.Filter
+	if filter != nil {
+		var err error
+		if qdrantFilter, err = toQdrantFilter(filter); err != nil {
+			return 0, err
+		}
+	}
+	if err := v.checkCollection(ctx, name); err != nil {
+		return 0, err
+	}
+	exact := true
+	count, err := v.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: name,
+		Filter:         qdrantFilter,
+		Exact:          &exact,
+	})
+	return int(count), err
+}
+
+// DropCollection 删除集合及其全部文档
+func (v *QdrantStore) DropCollection(ctx context.Context, name string) error {
+	if err := v.checkCollection(ctx, name); err != nil {
+		return err
+	}
+	if err := v.client.DeleteCollection(ctx, name); err != nil {
+		return err
+	}
+	// 别名可能指向被删除的集合，清空全部缓存
+	v.opened.Clear()
+	return nil
+}
+
+// RenameCollection Qdrant 不支持重命名集合，可以使用 CreateAlias 代替
+func (v *QdrantStore) RenameCollection(ctx context.Context, name, newName string) error {
+	return ErrNotSupported
+}
+
+// CreateAlias 为集合创建别名
+func (v *QdrantStore) CreateAlias(ctx context.Context, alias, name string) error {
+	if err := v.checkCollection(ctx, name); err != nil {
+		return err
+	}
+	if err := v.client.CreateAlias(ctx, alias, name); err != nil {
+		return err
+	}
+	v.opened.Delete(alias)
+	return nil
+}
+
+// DeleteAlias 删除别名，不影响集合本身
+func (v *QdrantStore) DeleteAlias(ctx context.Context, alias string) error {
+	if err := v.client.DeleteAlias(ctx, alias); err != nil {
+		return err
+	}
+	v.opened.Delete(alias)
+	return nil
+}
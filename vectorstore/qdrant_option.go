@@ -3,6 +3,7 @@ package vectorstore
 import (
 	"crypto/tls"
 	"github.com/hl540/rag/embedding"
+	"github.com/qdrant/go-client/qdrant"
 	"google.golang.org/grpc"
 )
 
@@ -55,3 +56,68 @@ func WithEmbedder(embedder embedding.Embedder) QdrantOption {
 		o.embedder = embedder
 	}
 }
+
+// WithDistance 设置新集合的距离度量，默认为 DistanceDot，已有集合的距离度量不一致时返回 ErrSchemaMismatch
+func WithDistance(distance Distance) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.distance = distance
+	}
+}
+
+// WithDimension 设置向量维度，默认使用第一批向量的维度，向量维度与之不一致时返回 ErrSchemaMismatch
+func WithDimension(dimension int) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.dimension = dimension
+	}
+}
+
+// WithOnDiskVectors 设置新集合的原始向量是否保存在磁盘上而不是内存中
+func WithOnDiskVectors(onDisk bool) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.onDisk = &onDisk
+	}
+}
+
+// WithHNSWIndex 设置新集合的 HNSW 索引参数，EfSearch 用于每次检索，为 0 的字段使用 Qdrant 的默认值
+func WithHNSWIndex(config HNSWConfig) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.hnsw = &config
+	}
+}
+
+// WithScalarQuantization 为新集合开启 int8 标量量化，alwaysRAM 为 true 时量化后的向量常驻内存
+func WithScalarQuantization(alwaysRAM bool) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.quantization = qdrant.NewQuantizationScalar(&qdrant.ScalarQuantization{
+			Type:      qdrant.QuantizationType_Int8,
+			AlwaysRam: &alwaysRAM,
+		})
+	}
+}
+
+// WithBinaryQuantization 为新集合开启二值量化，适合维度较高且经过归一化的向量
+func WithBinaryQuantization(alwaysRAM bool) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.quantization = qdrant.NewQuantizationBinary(&qdrant.BinaryQuantization{
+			AlwaysRam: &alwaysRAM,
+		})
+	}
+}
+
+// WithProductQuantization 为新集合开启乘积量化，ratio 为压缩比
+func WithProductQuantization(ratio qdrant.CompressionRatio, alwaysRAM bool) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.quantization = qdrant.NewQuantizationProduct(&qdrant.ProductQuantization{
+			Compression: ratio,
+			AlwaysRam:   &alwaysRAM,
+		})
+	}
+}
+
+// WithPayloadIndex 为元数据字段 key 建立 payload 索引，加快按该字段过滤，
+// 已有集合缺少该索引时会被补建，索引类型不一致时返回 ErrSchemaMismatch
+func WithPayloadIndex(key string, fieldType qdrant.FieldType) QdrantOption {
+	return func(o *QdrantStore) {
+		o.schema.payloadIndexes = append(o.schema.payloadIndexes, payloadIndex{key: key, fieldType: fieldType})
+	}
+}
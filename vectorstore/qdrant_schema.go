@@ -0,0 +1,200 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// qdrantSchema 是 QdrantStore 创建集合时使用的配置，打开已有集合时只检查显式设置过的字段
+type qdrantSchema struct {
+	distance       Distance // 为空时新集合使用 DistanceDot
+	dimension      int      // 为 0 时使用第一批向量的维度
+	onDisk         *bool
+	hnsw           *HNSWConfig
+	quantization   *qdrant.QuantizationConfig
+	payloadIndexes []payloadIndex
+}
+
+type payloadIndex struct {
+	key       string
+	fieldType qdrant.FieldType
+}
+
+var qdrantDistances = map[Distance]qdrant.Distance{
+	DistanceCosine:    qdrant.Distance_Cosine,
+	DistanceDot:       qdrant.Distance_Dot,
+	DistanceEuclid:    qdrant.Distance_Euclid,
+	DistanceManhattan: qdrant.Distance_Manhattan,
+}
+
+// validate 检查配置本身是否合法
+func (s *qdrantSchema) validate() error {
+	if _, ok := qdrantDistances[s.distance]; s.distance != "" && !ok {
+		return fmt.Errorf("vectorstore: unsupported distance %q", s.distance)
+	}
+	if s.dimension < 0 {
+		return fmt.Errorf("vectorstore: invalid dimension %d", s.dimension)
+	}
+	return nil
+}
+
+// openCollection 打开集合并检查其配置，结果会被缓存，同一个集合只检查一次
+// create 为 true 时集合不存在则按配置创建，否则返回 ErrCollectionNotFound。
+// dimension 为实际向量的维度，与集合的维度不一致时返回 ErrSchemaMismatch
+func (v *QdrantStore) openCollection(ctx context.Context, name string, dimension int, create bool) error {
+	if v.schema.dimension > 0 && dimension != v.schema.dimension {
+		return fmt.Errorf("%w: embedder returned %d dimensions, store is configured for %d", ErrSchemaMismatch, dimension, v.schema.dimension)
+	}
+	if size, ok := v.opened.Load(name); ok {
+		if size.(int) != dimension {
+			return fmt.Errorf("%w: collection %s has %d dimensions, got %d", ErrSchemaMismatch, name, size, dimension)
+		}
+		return nil
+	}
+
+	exists, err := v.client.CollectionExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if !create {
+			return ErrCollectionNotFound
+		}
+		if err := v.createCollection(ctx, name, dimension); err != nil {
+			return err
+		}
+	} else if err := v.checkSchema(ctx, name, dimension); err != nil {
+		return err
+	}
+	v.opened.Store(name, dimension)
+	return nil
+}
+
+// createCollection 按配置创建集合和 payload 索引
+func (v *QdrantStore) createCollection(ctx context.Context, name string, dimension int) error {
+	distance := v.schema.distance
+	if distance == "" {
+		distance = DistanceDot
+	}
+	request := &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     uint64(dimension),
+			Distance: qdrantDistances[distance],
+			OnDisk:   v.schema.onDisk,
+		}),
+		QuantizationConfig: v.schema.quantization,
+	}
+	if hnsw := v.schema.hnsw; hnsw != nil {
+		request.HnswConfig = &qdrant.HnswConfigDiff{}
+		if hnsw.M > 0 {
+			request.HnswConfig.M = qdrant.PtrOf(uint64(hnsw.M))
+		}
+		if hnsw.EfConstruction > 0 {
+			request.HnswConfig.EfConstruct = qdrant.PtrOf(uint64(hnsw.EfConstruction))
+		}
+	}
+	if err := v.client.CreateCollection(ctx, request); err != nil {
+		return err
+	}
+	for _, index := range v.schema.payloadIndexes {
+		if err := v.createPayloadIndex(ctx, name, index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *QdrantStore) createPayloadIndex(ctx context.Context, name string, index payloadIndex) error {
+	wait := true
+	_, err := v.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+		CollectionName: name,
+		Wait:           &wait,
+		FieldName:      index.key,
+		FieldType:      qdrant.PtrOf(index.fieldType),
+	})
+	return err
+}
+
+// checkSchema 检查已有集合的配置与显式设置的配置是否一致，缺少的 payload 索引会被补建
+func (v *QdrantStore) checkSchema(ctx context.Context, name string, dimension int) error {
+	info, err := v.client.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return err
+	}
+	config := info.GetConfig()
+	params := config.GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return fmt.Errorf("%w: collection %s uses named vectors", ErrSchemaMismatch, name)
+	}
+	mismatch := func(field string, got, want any) error {
+		return fmt.Errorf("%w: collection %s has %s %v, expected %v", ErrSchemaMismatch, name, field, got, want)
+	}
+
+	if params.GetSize() != uint64(dimension) {
+		return mismatch("dimension", params.GetSize(), dimension)
+	}
+	if s := v.schema.distance; s != "" && params.GetDistance() != qdrantDistances[s] {
+		return mismatch("distance", params.GetDistance(), qdrantDistances[s])
+	}
+	if s := v.schema.onDisk; s != nil && params.GetOnDisk() != *s {
+		return mismatch("on_disk", params.GetOnDisk(), *s)
+	}
+	if s := v.schema.hnsw; s != nil {
+		hnsw := config.GetHnswConfig()
+		if params.GetHnswConfig() != nil {
+			hnsw = params.GetHnswConfig()
+		}
+		if s.M > 0 && hnsw.GetM() != uint64(s.M) {
+			return mismatch("hnsw m", hnsw.GetM(), s.M)
+		}
+		if s.EfConstruction > 0 && hnsw.GetEfConstruct() != uint64(s.EfConstruction) {
+			return mismatch("hnsw ef_construct", hnsw.GetEfConstruct(), s.EfConstruction)
+		}
+	}
+	if s := v.schema.quantization; s != nil {
+		quantization := config.GetQuantizationConfig()
+		if params.GetQuantizationConfig() != nil {
+			quantization = params.GetQuantizationConfig()
+		}
+		if got, want := quantizationKind(quantization), quantizationKind(s); got != want {
+			return mismatch("quantization", got, want)
+		}
+	}
+	for _, index := range v.schema.payloadIndexes {
+		schema, ok := info.GetPayloadSchema()[index.key]
+		if !ok {
+			if err := v.createPayloadIndex(ctx, name, index); err != nil {
+				return err
+			}
+			continue
+		}
+		// PayloadSchemaType 比 FieldType 多一个 UnknownType，其余取值依次对应
+		if want := qdrant.PayloadSchemaType(index.fieldType + 1); schema.GetDataType() != want {
+			return mismatch("payload index "+index.key, schema.GetDataType(), want)
+		}
+	}
+	return nil
+}
+
+// quantizationKind 返回量化方式的名称，未量化时返回 "none"
+func quantizationKind(config *qdrant.QuantizationConfig) string {
+	switch {
+	case config.GetScalar() != nil:
+		return "scalar"
+	case config.GetBinary() != nil:
+		return "binary"
+	case config.GetProduct() != nil:
+		return "product"
+	}
+	return "none"
+}
+
+// searchParams 返回检索参数，没有设置 EfSearch 时返回 nil，使用集合的默认值
+func (v *QdrantStore) searchParams() *qdrant.SearchParams {
+	if v.schema.hnsw == nil || v.schema.hnsw.EfSearch <= 0 {
+		return nil
+	}
+	return &qdrant.SearchParams{HnswEf: qdrant.PtrOf(uint64(v.schema.hnsw.EfSearch))}
+}
@@ -0,0 +1,137 @@
+package vectorstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/qdrant/go-client/qdrant"
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ToQdrantValue 将 Go 值转换为 qdrant.Value，支持的类型比 qdrant.NewValue 更多，并且不会 panic：
+// nil、布尔值、字符串、全部整数和浮点数类型、json.Number、任意元素类型的切片和数组、
+// 键为字符串的任意 map，以及指向这些类型的指针。[]byte 与 qdrant.NewValue 一致，保存为 base64 字符串
+func ToQdrantValue(v any) (*qdrant.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return qdrant.NewValueNull(), nil
+	case *qdrant.Value:
+		return v, nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return qdrant.NewValueInt(n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewValueDouble(f), nil
+	case []byte:
+		return qdrant.NewValueString(base64.StdEncoding.EncodeToString(v)), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return qdrant.NewValueBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return qdrant.NewValueInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := rv.Uint()
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("vectorstore: integer %d overflows int64", n)
+		}
+		return qdrant.NewValueInt(int64(n)), nil
+	case reflect.Float32, reflect.Float64:
+		return qdrant.NewValueDouble(rv.Float()), nil
+	case reflect.String:
+		s := rv.String()
+		if !utf8.ValidString(s) {
+			return nil, fmt.Errorf("vectorstore: invalid UTF-8 in string %q", s)
+		}
+		return qdrant.NewValueString(s), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		return ToQdrantValue(rv.Elem().Interface())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		values := make([]*qdrant.Value, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			value, err := ToQdrantValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			values = append(values, value)
+		}
+		return qdrant.NewValueList(&qdrant.ListValue{Values: values}), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("vectorstore: unsupported map key type %s", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return qdrant.NewValueNull(), nil
+		}
+		fields := make(map[string]*qdrant.Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			value, err := ToQdrantValue(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			fields[key] = value
+		}
+		return qdrant.NewValueStruct(&qdrant.Struct{Fields: fields}), nil
+	}
+	return nil, fmt.Errorf("vectorstore: unsupported value type %T", v)
+}
+
+// FromQdrantValue 将 qdrant.Value 转换为 Go 值，是 ToQdrantValue 的逆操作
+// 整数返回 int64，浮点数返回 float64，列表返回 []any，对象返回 map[string]any，null 返回 nil
+func FromQdrantValue(value *qdrant.Value) any {
+	switch kind := value.GetKind().(type) {
+	case *qdrant.Value_BoolValue:
+		return kind.BoolValue
+	case *qdrant.Value_IntegerValue:
+		return kind.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		return kind.DoubleValue
+	case *qdrant.Value_StringValue:
+		return kind.StringValue
+	case *qdrant.Value_ListValue:
+		list := make([]any, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			list = append(list, FromQdrantValue(item))
+		}
+		return list
+	case *qdrant.Value_StructValue:
+		fields := make(map[string]any, len(kind.StructValue.GetFields()))
+		for key, item := range kind.StructValue.GetFields() {
+			fields[key] = FromQdrantValue(item)
+		}
+		return fields
+	}
+	return nil
+}
+
+// toPayload 将元数据转换为 Qdrant payload，元数据使用保留字段时返回 ErrReservedKey
+func toPayload(metadata map[string]any) (map[string]*qdrant.Value, error) {
+	if err := checkMetadata(metadata); err != nil {
+		return nil, err
+	}
+	payload := make(map[string]*qdrant.Value, len(metadata)+2)
+	for key, v := range metadata {
+		value, err := ToQdrantValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("metadata %s: %w", key, err)
+		}
+		payload[key] = value
+	}
+	return payload, nil
+}
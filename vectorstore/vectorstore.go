@@ -1,10 +1,17 @@
 package vectorstore
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hl540/rag/embedding"
+	"math"
+)
 
 type SearchResult struct {
 	ID       string
 	Score    float32
+	Text     string
 	Metadata map[string]any
 }
 
@@ -12,11 +19,216 @@ type Document struct {
 	Id       string
 	Text     string
 	Metadata map[string]any
+	// Embedding 是文档的向量，AddDocuments 只为没有向量的文档生成向量嵌入，
+	// 因此导入导出的文档时不需要重新生成。已有的向量必须来自与向量存储相同的嵌入器
+	Embedding []float32
 }
 
+// ContentKey 是 documentloader 在元数据中保存文本时使用的字段，文本已经通过 Text 返回，不需要再从元数据读取
 const ContentKey = "content"
 
+// TextKey 是 QdrantStore 保存文档文本的保留 payload 字段
+const TextKey = "_text"
+
+// NormalizedKey 是 QdrantStore 记录向量是否经过 L2 归一化的保留 payload 字段
+const NormalizedKey = "_normalized"
+
+// ModelKey 是 QdrantStore 记录生成向量的模型名称的保留 payload 字段
+const ModelKey = "_model"
+
+// ErrNormalizationMismatch 表示向量的归一化状态与集合中已有的向量不一致
+var ErrNormalizationMismatch = errors.New("vectorstore: cannot mix normalized and unnormalized vectors in one collection")
+
+// ErrModelMismatch 表示向量嵌入器的模型与集合中已有向量的模型不一致
+var ErrModelMismatch = errors.New("vectorstore: embedder model does not match collection")
+
+// ErrCollectionExists 表示同名集合已经存在
+var ErrCollectionExists = errors.New("vectorstore: collection already exists")
+
+// ErrNotSupported 表示后端不支持该操作
+var ErrNotSupported = errors.New("vectorstore: operation not supported by backend")
+
+// ErrSchemaMismatch 表示已有集合的配置与向量存储的配置不一致
+var ErrSchemaMismatch = errors.New("vectorstore: collection schema mismatch")
+
+// ErrInvalidEmbedding 表示文档自带的向量包含 NaN、无穷大或者是零向量
+var ErrInvalidEmbedding = errors.New("vectorstore: invalid embedding")
+
+// ErrReservedKey 表示文档元数据使用了 TextKey、NormalizedKey 或 ModelKey 等保留字段
+var ErrReservedKey = errors.New("vectorstore: reserved metadata key")
+
+// Distance 是向量的距离度量
+type Distance string
+
+const (
+	DistanceCosine    Distance = "cosine"
+	DistanceDot       Distance = "dot"
+	DistanceEuclid    Distance = "euclid"
+	DistanceManhattan Distance = "manhattan"
+)
+
+// ErrCollectionNotFound 表示集合不存在
+var ErrCollectionNotFound = errors.New("vectorstore: no such collection")
+
 type VectorStore interface {
+	// AddDocuments 写入文档，ID 已存在的文档会被替换
 	AddDocuments(ctx context.Context, name string, docs []*Document) error
-	SimilaritySearch(ctx context.Context, name string, query string, topK int) ([]*SearchResult, error)
+	// SimilaritySearch 搜索与查询最相似的 topK 条记录，可以通过 WithFilter 按元数据预先过滤
+	SimilaritySearch(ctx context.Context, name string, query string, topK int, opts ...SearchOption) ([]*SearchResult, error)
+	// GetDocuments 按 ID 读取文档，不存在的 ID 会被忽略，结果按 ids 的顺序返回
+	GetDocuments(ctx context.Context, name string, ids []string) ([]*Document, error)
+	// DeleteDocuments 按 ID 删除文档，不存在的 ID 会被忽略
+	DeleteDocuments(ctx context.Context, name string, ids []string) error
+	// DeleteByFilter 删除元数据满足 filter 的全部文档
+	DeleteByFilter(ctx context.Context, name string, filter *Filter) error
+}
+
+// CollectionInfo 是集合的基本信息
+type CollectionInfo struct {
+	Name       string
+	Count      int      // 文档数量，Qdrant 返回的是近似值
+	Dimension  int      // 向量维度，集合为空且维度未知时为 0
+	Distance   Distance // 距离度量
+	Model      string   // 生成向量的模型名称，未知时为空
+	Normalized bool     // 向量是否经过 L2 归一化
+	Aliases    []string // 指向该集合的别名
+}
+
+// CollectionAdmin 是集合的管理接口，MemoryStore 和 QdrantStore 都实现了该接口
+// 后端不支持的操作返回 ErrNotSupported：MemoryStore 不支持别名，QdrantStore 不支持重命名
+type CollectionAdmin interface {
+	// ListCollections 返回全部集合的名称，按名称排序
+	ListCollections(ctx context.Context) ([]string, error)
+	CollectionExists(ctx context.Context, name string) (bool, error)
+	// CollectionInfo 返回集合的信息，集合不存在时返回 ErrCollectionNotFound
+	CollectionInfo(ctx context.Context, name string) (*CollectionInfo, error)
+	// Count 返回元数据满足 filter 的文档数量，filter 为 nil 时返回全部文档数量
+	Count(ctx context.Context, name string, filter *Filter) (int, error)
+	// DropCollection 删除集合及其全部文档，集合不存在时返回 ErrCollectionNotFound
+	DropCollection(ctx context.Context, name string) error
+	// RenameCollection 重命名集合，newName 已存在时返回 ErrCollectionExists
+	RenameCollection(ctx context.Context, name, newName string) error
+	// CreateAlias 为集合创建别名，之后可以用别名代替集合名称
+	CreateAlias(ctx context.Context, alias, name string) error
+	DeleteAlias(ctx context.Context, alias string) error
+}
+
+// Scroller 由支持按页遍历集合中全部文档的向量存储实现，MemoryStore 和 QdrantStore 都实现了该接口
+type Scroller interface {
+	// ScrollDocuments 按 ID 顺序返回一页文档，集合不存在时返回 ErrCollectionNotFound
+	ScrollDocuments(ctx context.Context, name string, opts ScrollOptions) (*ScrollPage, error)
+}
+
+// ScrollOptions 是 ScrollDocuments 的参数
+type ScrollOptions struct {
+	Offset         string  // 从该 ID 开始读取，为空时从头读取，通常为上一页的 NextOffset
+	Limit          int     // 每页最多返回的文档数量，小于等于 0 时使用 100
+	Filter         *Filter // 只返回元数据满足 filter 的文档，为 nil 时返回全部文档
+	WithEmbeddings bool    // 是否同时返回文档的向量
+}
+
+// ScrollPage 是 ScrollDocuments 返回的一页文档
+type ScrollPage struct {
+	Documents  []*Document
+	NextOffset string // 下一页的起始 ID，为空表示没有更多文档
+}
+
+// SearchOptions 是 SimilaritySearch 的可选参数
+type SearchOptions struct {
+	Filter *Filter
+	Exact  bool
+}
+
+type SearchOption func(o *SearchOptions)
+
+// WithFilter 只在元数据满足 filter 的文档中搜索，过滤在计算相似度之前进行，因此 topK 不会因过滤而减少
+func WithFilter(filter *Filter) SearchOption {
+	return func(o *SearchOptions) {
+		o.Filter = filter
+	}
+}
+
+// WithExactSearch 不使用近似最近邻索引，逐条计算相似度得到精确结果
+func WithExactSearch() SearchOption {
+	return func(o *SearchOptions) {
+		o.Exact = true
+	}
+}
+
+// newSearchOptions 应用可选参数并校验过滤表达式
+func newSearchOptions(opts []SearchOption) (*SearchOptions, error) {
+	options := &SearchOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.Filter != nil {
+		if err := options.Filter.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// embedDocuments 返回每个文档的向量，只为没有向量的文档生成向量嵌入
+// 文档自带的向量不合法时返回 ErrInvalidEmbedding，各文档的向量维度不一致时返回 ErrSchemaMismatch
+func embedDocuments(ctx context.Context, embedder embedding.Embedder, docs []*Document) ([][]float32, error) {
+	embeds := make([][]float32, len(docs))
+	missing := make([]int, 0, len(docs))
+	texts := make([]string, 0, len(docs))
+	for i, doc := range docs {
+		if len(doc.Embedding) > 0 {
+			if err := validateEmbedding(doc.Embedding); err != nil {
+				return nil, fmt.Errorf("document %s: %w", doc.Id, err)
+			}
+			embeds[i] = doc.Embedding
+			continue
+		}
+		missing = append(missing, i)
+		texts = append(texts, doc.Text)
+	}
+	if len(texts) > 0 {
+		generated, err := embedding.EmbedDocuments(ctx, embedder, texts)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range missing {
+			embeds[i] = generated[j]
+		}
+	}
+	return embeds, checkDimension(docs, embeds, len(embeds[0]))
+}
+
+// checkMetadata 检查元数据没有使用保留字段，保证各后端读回的元数据与写入的一致
+func checkMetadata(metadata map[string]any) error {
+	for _, key := range []string{TextKey, NormalizedKey, ModelKey} {
+		if _, ok := metadata[key]; ok {
+			return fmt.Errorf("%w: %s", ErrReservedKey, key)
+		}
+	}
+	return nil
+}
+
+// validateEmbedding 检查向量不包含 NaN 或无穷大，并且不是零向量
+func validateEmbedding(embed []float32) error {
+	var sum float64
+	for _, x := range embed {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return fmt.Errorf("%w: contains %v", ErrInvalidEmbedding, x)
+		}
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return fmt.Errorf("%w: zero vector", ErrInvalidEmbedding)
+	}
+	return nil
+}
+
+// checkDimension 检查每个文档的向量维度都等于 dimension
+func checkDimension(docs []*Document, embeds [][]float32, dimension int) error {
+	for i, embed := range embeds {
+		if len(embed) != dimension {
+			return fmt.Errorf("%w: document %s has %d dimensions, expected %d", ErrSchemaMismatch, docs[i].Id, len(embed), dimension)
+		}
+	}
+	return nil
 }